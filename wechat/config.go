@@ -9,12 +9,19 @@ import (
 
 // A structure containing a Wechat app's parameter configuration.
 type AppConfig struct {
-	// The app id. (required)
+	// The app id. (required) For official account, mini program ...
 	AppID string `json:"app_id"`
 
 	// App secret.
 	AppSecret string `json:"app_secret"`
 
+	// Open platform (open.weixin.qq.com) website app id, it's a different app from
+	// the above and is used for website QR login (snsapi_login).
+	OpenAppID string `json:"open_app_id"`
+
+	// Open platform website app secret.
+	OpenAppSecret string `json:"open_app_secret"`
+
 	// Wechat payment merchandiser id.
 	PayMchID string `json:"pay_mch_id"`
 
@@ -81,4 +88,21 @@ func (config *AppConfig) PayClientTLSConfig() (*tls.Config, error) {
 
 }
 
+// Return a config of the open platform website app, i.e. AppID/AppSecret are
+// replaced by OpenAppID/OpenAppSecret. Use it to create OAuth2 for website QR login.
+func (config *AppConfig) WebsiteAppConfig() (*AppConfig, error) {
+
+	if config.OpenAppID == "" || config.OpenAppSecret == "" {
+
+		return nil, fmt.Errorf("missing OpenAppID/OpenAppSecret")
+
+	}
+
+	return &AppConfig{
+		AppID:     config.OpenAppID,
+		AppSecret: config.OpenAppSecret,
+	}, nil
+
+}
+
 var DefaultAPITimeout time.Duration = 30 * time.Second
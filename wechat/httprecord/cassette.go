@@ -0,0 +1,83 @@
+// Package httprecord records HTTP exchanges between this library and Wechat's
+// services (pay and oauth2) and replays them deterministically. It is intended
+// for turning real production incidents into regression tests.
+//
+// Record:
+//
+//	rec := httprecord.NewRecorder(nil)
+//	p, _ := pay.NewPay(config, &http.Client{Transport: rec})
+//	...
+//	rec.Cassette().Save("testdata/incident.json")
+//
+// Replay:
+//
+//	c, _ := httprecord.LoadCassette("testdata/incident.json")
+//	rep := httprecord.NewReplayer(c)
+//	p, _ := pay.NewPay(config, &http.Client{Transport: rep})
+//	rep.Pay = p // Re-sign pay responses since recorded signs are redacted.
+//
+// Secrets (app secret, tokens, signs ...) are redacted when recording. Requests
+// are matched by method, API path and significant business fields: secret
+// fields and volatile fields such as nonce_str/sign/timestamp are ignored, so
+// random nonces do not break replay. Set Pay.NonceSource/Pay.Clock (or
+// OAuth2.NonceSource/OAuth2.Clock) as well if byte-identical requests are wanted.
+package httprecord
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+)
+
+// One recorded request/response exchange.
+type Interaction struct {
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestBody    string      `json:"request_body,omitempty"`
+	StatusCode     int         `json:"status_code"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+	ResponseBody   string      `json:"response_body,omitempty"`
+}
+
+// A list of recorded interactions.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Load cassette from a JSON file.
+func LoadCassette(path string) (*Cassette, error) {
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	c := &Cassette{}
+
+	if err := json.Unmarshal(data, c); err != nil {
+
+		return nil, err
+
+	}
+
+	return c, nil
+
+}
+
+// Save cassette to a JSON file.
+func (c *Cassette) Save(path string) error {
+
+	data, err := json.MarshalIndent(c, "", "  ")
+
+	if err != nil {
+
+		return err
+
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+
+}
@@ -0,0 +1,312 @@
+package httprecord
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+)
+
+// Placeholder of redacted values.
+const REDACTED = "REDACTED"
+
+// Default field names whose values are secrets. They are redacted when
+// recording and ignored when matching.
+var DefaultSecretKeys = []string{
+	"secret",
+	"appsecret",
+	"access_token",
+	"refresh_token",
+	"session_key",
+	"ticket",
+	"sign",
+	"signature",
+	"msg_signature",
+	"paySign",
+}
+
+// Default field names whose values change every call. They are ignored when
+// matching.
+var DefaultVolatileKeys = []string{
+	"nonce_str",
+	"noncestr",
+	"nonceStr",
+	"nonce",
+	"timestamp",
+	"timeStamp",
+}
+
+// A parsed body: a one level pay XML or a JSON object.
+type bodyKind int
+
+const (
+	bodyRaw bodyKind = iota
+	bodyXML
+	bodyJSON
+)
+
+type xmlDict struct {
+	XMLName xml.Name   `xml:"xml"`
+	Fields  []xmlField `xml:",any"`
+}
+
+type xmlField struct {
+	XMLName xml.Name
+	Text    string `xml:",chardata"`
+}
+
+// Parsed top level fields of a body, in their original order.
+type bodyFields struct {
+	keys   []string
+	values map[string]string
+}
+
+func (f *bodyFields) set(k, v string) {
+
+	if _, ok := f.values[k]; !ok {
+
+		f.keys = append(f.keys, k)
+
+	}
+
+	f.values[k] = v
+
+}
+
+// Parse body into top level fields. Values of JSON fields are kept as raw JSON.
+func parseBody(body []byte) (*bodyFields, bodyKind) {
+
+	trimmed := bytes.TrimSpace(body)
+
+	if len(trimmed) == 0 {
+
+		return nil, bodyRaw
+
+	}
+
+	ret := &bodyFields{values: make(map[string]string)}
+
+	switch trimmed[0] {
+
+	case '<':
+
+		d := &xmlDict{}
+
+		if err := xml.Unmarshal(trimmed, d); err != nil {
+
+			return nil, bodyRaw
+
+		}
+
+		for _, f := range d.Fields {
+
+			ret.set(f.XMLName.Local, f.Text)
+
+		}
+
+		return ret, bodyXML
+
+	case '{':
+
+		// Decode token by token to keep the order of keys.
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+
+		if _, err := dec.Token(); err != nil {
+
+			return nil, bodyRaw
+
+		}
+
+		for dec.More() {
+
+			tok, err := dec.Token()
+
+			if err != nil {
+
+				return nil, bodyRaw
+
+			}
+
+			k, ok := tok.(string)
+
+			if !ok {
+
+				return nil, bodyRaw
+
+			}
+
+			var v json.RawMessage
+
+			if err := dec.Decode(&v); err != nil {
+
+				return nil, bodyRaw
+
+			}
+
+			ret.set(k, string(v))
+
+		}
+
+		if _, err := dec.Token(); err != nil {
+
+			return nil, bodyRaw
+
+		}
+
+		return ret, bodyJSON
+
+	}
+
+	return nil, bodyRaw
+
+}
+
+// Reverse operation of parseBody. Fields are encoded in their original order.
+func encodeBody(fields *bodyFields, kind bodyKind) []byte {
+
+	switch kind {
+
+	case bodyXML:
+
+		d := &xmlDict{}
+
+		for _, k := range fields.keys {
+
+			d.Fields = append(d.Fields, xmlField{
+				XMLName: xml.Name{Local: k},
+				Text:    fields.values[k],
+			})
+
+		}
+
+		buf, _ := xml.Marshal(d)
+
+		return buf
+
+	case bodyJSON:
+
+		var buf bytes.Buffer
+
+		buf.WriteByte('{')
+
+		for i, k := range fields.keys {
+
+			if i > 0 {
+
+				buf.WriteByte(',')
+
+			}
+
+			key, _ := json.Marshal(k)
+
+			buf.Write(key)
+
+			buf.WriteByte(':')
+
+			buf.WriteString(fields.values[k])
+
+		}
+
+		buf.WriteByte('}')
+
+		return buf.Bytes()
+
+	}
+
+	return nil
+
+}
+
+// Replace values of secret keys in body. Return body unchanged if it can't be parsed.
+func redactBody(body []byte, secret_keys map[string]bool) []byte {
+
+	fields, kind := parseBody(body)
+
+	if kind == bodyRaw {
+
+		return body
+
+	}
+
+	changed := false
+
+	for _, k := range fields.keys {
+
+		if secret_keys[k] {
+
+			if kind == bodyJSON {
+
+				fields.values[k] = `"` + REDACTED + `"`
+
+			} else {
+
+				fields.values[k] = REDACTED
+
+			}
+
+			changed = true
+
+		}
+
+	}
+
+	if !changed {
+
+		return body
+
+	}
+
+	return encodeBody(fields, kind)
+
+}
+
+// Replace values of secret keys in URL query.
+func redactURL(u *url.URL, secret_keys map[string]bool) string {
+
+	q := u.Query()
+
+	changed := false
+
+	for k := range q {
+
+		if secret_keys[k] {
+
+			q.Set(k, REDACTED)
+
+			changed = true
+
+		}
+
+	}
+
+	if !changed {
+
+		return u.String()
+
+	}
+
+	r := *u
+
+	r.RawQuery = q.Encode()
+
+	return r.String()
+
+}
+
+func keySet(lists ...[]string) map[string]bool {
+
+	ret := make(map[string]bool)
+
+	for _, list := range lists {
+
+		for _, k := range list {
+
+			ret[k] = true
+
+		}
+
+	}
+
+	return ret
+
+}
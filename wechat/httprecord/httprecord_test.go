@@ -0,0 +1,157 @@
+package httprecord
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactBodyKeepsOrder(t *testing.T) {
+
+	secret_keys := keySet(DefaultSecretKeys)
+
+	for _, c := range []struct {
+		Body   string
+		Expect string
+	}{
+		{
+			`<xml><return_code>SUCCESS</return_code><sign>ABC</sign><appid>wx1</appid><nonce_str>n</nonce_str></xml>`,
+			`<xml><return_code>SUCCESS</return_code><sign>REDACTED</sign><appid>wx1</appid><nonce_str>n</nonce_str></xml>`,
+		},
+		{
+			`{"openid":"o1","access_token":"T","expires_in":7200,"scope":{"a":1}}`,
+			`{"openid":"o1","access_token":"REDACTED","expires_in":7200,"scope":{"a":1}}`,
+		},
+	} {
+
+		// Repeat to catch map iteration order.
+		for i := 0; i < 20; i++ {
+
+			if got := string(redactBody([]byte(c.Body), secret_keys)); got != c.Expect {
+
+				t.Fatalf("expect %s, got %s", c.Expect, got)
+
+			}
+
+		}
+
+	}
+
+}
+
+func TestRecordReplay(t *testing.T) {
+
+	const resp_body = `{"openid":"o1","access_token":"T","expires_in":7200}`
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		w.Header().Set("Content-Type", "application/json")
+
+		w.Write([]byte(resp_body))
+
+	}))
+
+	defer srv.Close()
+
+	rec := NewRecorder(nil)
+
+	client := &http.Client{Transport: rec}
+
+	resp, err := client.Post(srv.URL+"/sns/oauth2?appid=wx1&secret=S", "application/json",
+		strings.NewReader(`{"code":"c1","nonce":"n1"}`))
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	resp.Body.Close()
+
+	dir := t.TempDir()
+
+	path1, path2 := filepath.Join(dir, "1.json"), filepath.Join(dir, "2.json")
+
+	if err := rec.Cassette().Save(path1); err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	c, err := LoadCassette(path1)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if err := c.Save(path2); err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	data1, _ := ioutil.ReadFile(path1)
+
+	data2, _ := ioutil.ReadFile(path2)
+
+	if !bytes.Equal(data1, data2) {
+
+		t.Fatalf("cassette not stable:\n%s\n%s", data1, data2)
+
+	}
+
+	if strings.Contains(string(data1), `"T"`) || strings.Contains(string(data1), "secret=S") {
+
+		t.Fatalf("secrets not redacted:\n%s", data1)
+
+	}
+
+	// Replay with a different nonce; the request body must still be readable afterwards.
+	rep := NewReplayer(c)
+
+	req, _ := http.NewRequest("POST", srv.URL+"/sns/oauth2?appid=wx1&secret=S2",
+		strings.NewReader(`{"code":"c1","nonce":"n2"}`))
+
+	resp, err = rep.RoundTrip(req)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	got, _ := ioutil.ReadAll(resp.Body)
+
+	if expect := `{"openid":"o1","access_token":"REDACTED","expires_in":7200}`; string(got) != expect {
+
+		t.Fatalf("expect %s, got %s", expect, got)
+
+	}
+
+	req_body, _ := ioutil.ReadAll(resp.Request.Body)
+
+	if string(req_body) != `{"code":"c1","nonce":"n2"}` {
+
+		t.Fatalf("request body not restored: %+q", req_body)
+
+	}
+
+	if _, err := rep.RoundTrip(req); err == nil {
+
+		t.Fatal("expect error when the interaction is used up")
+
+	}
+
+	if len(rep.Unused()) != 0 {
+
+		t.Fatal("expect no unused interaction")
+
+	}
+
+}
@@ -0,0 +1,120 @@
+package httprecord
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Recorder is a http.RoundTripper which forwards requests to an underlying
+// transport and records (redacted) exchanges.
+type Recorder struct {
+	// The underlying transport.
+	base http.RoundTripper
+
+	// Field names (in URL query or body) to redact. Default to DefaultSecretKeys.
+	SecretKeys []string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// Create a Recorder forwarding requests to base. If base is nil,
+// http.DefaultTransport is used. NOTE: pay APIs need tls client cert, pass
+// a transport configured with AppConfig.PayClientTLSConfig in that case.
+func NewRecorder(base http.RoundTripper) *Recorder {
+
+	if base == nil {
+
+		base = http.DefaultTransport
+
+	}
+
+	return &Recorder{
+		base:       base,
+		SecretKeys: DefaultSecretKeys,
+	}
+
+}
+
+// Implement http.RoundTripper.
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	var req_body []byte
+
+	if req.Body != nil {
+
+		var err error
+
+		req_body, err = ioutil.ReadAll(req.Body)
+
+		req.Body.Close()
+
+		if err != nil {
+
+			return nil, err
+
+		}
+
+		req.Body = ioutil.NopCloser(bytes.NewReader(req_body))
+
+	}
+
+	resp, err := rec.base.RoundTrip(req)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	resp_body, err := ioutil.ReadAll(resp.Body)
+
+	resp.Body.Close()
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(resp_body))
+
+	secret_keys := keySet(rec.SecretKeys)
+
+	header := http.Header{}
+
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+
+		header.Set("Content-Type", ct)
+
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.cassette.Interactions = append(rec.cassette.Interactions, &Interaction{
+		Method:         req.Method,
+		URL:            redactURL(req.URL, secret_keys),
+		RequestBody:    string(redactBody(req_body, secret_keys)),
+		StatusCode:     resp.StatusCode,
+		ResponseHeader: header,
+		ResponseBody:   string(redactBody(resp_body, secret_keys)),
+	})
+
+	return resp, nil
+
+}
+
+// Return a copy of what has been recorded so far.
+func (rec *Recorder) Cassette() *Cassette {
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return &Cassette{
+		Interactions: append([]*Interaction(nil), rec.cassette.Interactions...),
+	}
+
+}
@@ -0,0 +1,279 @@
+package httprecord
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/huangjunwen/WechatDriver/wechat/pay"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// Replayer is a http.RoundTripper which serves responses from a cassette
+// instead of the network. Each interaction is served at most once, in
+// recorded order among those matching.
+type Replayer struct {
+	cassette *Cassette
+
+	// Field names ignored when matching. Default to DefaultSecretKeys and
+	// DefaultVolatileKeys.
+	IgnoreKeys []string
+
+	// If not nil, pay XML responses are re-signed using its key and the request's
+	// sign_type, so that they pass signature verification after redaction.
+	Pay *pay.Pay
+
+	mu   sync.Mutex
+	used []bool
+}
+
+// Create a Replayer serving interactions in c.
+func NewReplayer(c *Cassette) *Replayer {
+
+	ignore_keys := append([]string(nil), DefaultSecretKeys...)
+
+	ignore_keys = append(ignore_keys, DefaultVolatileKeys...)
+
+	return &Replayer{
+		cassette:   c,
+		IgnoreKeys: ignore_keys,
+		used:       make([]bool, len(c.Interactions)),
+	}
+
+}
+
+// Implement http.RoundTripper.
+func (rep *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	var req_body []byte
+
+	if req.Body != nil {
+
+		var err error
+
+		req_body, err = ioutil.ReadAll(req.Body)
+
+		req.Body.Close()
+
+		if err != nil {
+
+			return nil, err
+
+		}
+
+		// Restore it for others (e.g. resp.Request) to read.
+		req.Body = ioutil.NopCloser(bytes.NewReader(req_body))
+
+	}
+
+	ignore_keys := keySet(rep.IgnoreKeys)
+
+	rep.mu.Lock()
+
+	var found *Interaction
+
+	for i, inter := range rep.cassette.Interactions {
+
+		if rep.used[i] || !match(inter, req, req_body, ignore_keys) {
+
+			continue
+
+		}
+
+		rep.used[i] = true
+
+		found = inter
+
+		break
+
+	}
+
+	rep.mu.Unlock()
+
+	if found == nil {
+
+		return nil, fmt.Errorf("httprecord: no recorded interaction matches %s %s", req.Method, req.URL.Path)
+
+	}
+
+	resp_body := []byte(found.ResponseBody)
+
+	if rep.Pay != nil {
+
+		resp_body = rep.resign(resp_body, req_body)
+
+	}
+
+	header := http.Header{}
+
+	for k, v := range found.ResponseHeader {
+
+		header[k] = append([]string(nil), v...)
+
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", found.StatusCode, http.StatusText(found.StatusCode)),
+		StatusCode:    found.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(resp_body)),
+		ContentLength: int64(len(resp_body)),
+		Request:       req,
+	}, nil
+
+}
+
+// Return indexes of interactions not yet served.
+func (rep *Replayer) Unused() []int {
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	var ret []int
+
+	for i, used := range rep.used {
+
+		if !used {
+
+			ret = append(ret, i)
+
+		}
+
+	}
+
+	return ret
+
+}
+
+func (rep *Replayer) resign(resp_body, req_body []byte) []byte {
+
+	fields, kind := parseBody(resp_body)
+
+	if kind != bodyXML {
+
+		return resp_body
+
+	}
+
+	if _, ok := fields.values["sign"]; !ok {
+
+		return resp_body
+
+	}
+
+	sign_type := ""
+
+	if req_fields, _ := parseBody(req_body); req_fields != nil {
+
+		sign_type = req_fields.values["sign_type"]
+
+	}
+
+	// Keep sign at its original position.
+	values := make(map[string]string, len(fields.values))
+
+	for k, v := range fields.values {
+
+		if k != "sign" {
+
+			values[k] = v
+
+		}
+
+	}
+
+	fields.values["sign"] = rep.Pay.Sign(values, pay.SignType(sign_type))
+
+	return encodeBody(fields, kind)
+
+}
+
+func match(inter *Interaction, req *http.Request, req_body []byte, ignore_keys map[string]bool) bool {
+
+	if inter.Method != req.Method {
+
+		return false
+
+	}
+
+	u, err := url.Parse(inter.URL)
+
+	if err != nil || u.Host != req.URL.Host || u.Path != req.URL.Path {
+
+		return false
+
+	}
+
+	if !sameFields(flatten(u.Query()), flatten(req.URL.Query()), ignore_keys) {
+
+		return false
+
+	}
+
+	recorded, recorded_kind := parseBody([]byte(inter.RequestBody))
+
+	actual, actual_kind := parseBody(req_body)
+
+	if recorded_kind != actual_kind {
+
+		return false
+
+	}
+
+	if recorded_kind == bodyRaw {
+
+		return bytes.Equal(bytes.TrimSpace([]byte(inter.RequestBody)), bytes.TrimSpace(req_body))
+
+	}
+
+	return sameFields(recorded.values, actual.values, ignore_keys)
+
+}
+
+func flatten(q url.Values) map[string]string {
+
+	ret := make(map[string]string, len(q))
+
+	for k, v := range q {
+
+		if len(v) > 0 {
+
+			ret[k] = v[0]
+
+		}
+
+	}
+
+	return ret
+
+}
+
+func sameFields(a, b map[string]string, ignore_keys map[string]bool) bool {
+
+	for k, v := range a {
+
+		if !ignore_keys[k] && b[k] != v {
+
+			return false
+
+		}
+
+	}
+
+	for k, v := range b {
+
+		if !ignore_keys[k] && a[k] != v {
+
+			return false
+
+		}
+
+	}
+
+	return true
+
+}
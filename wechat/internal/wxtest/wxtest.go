@@ -0,0 +1,66 @@
+// Package wxtest holds helpers shared by tests (and fake servers) of this library.
+package wxtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// RewriteTransport redirects all requests (whatever the scheme and host are) to
+// Target.
+type RewriteTransport struct {
+	Target *url.URL
+
+	// The underlying transport. Default to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+func (t *RewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	base := t.Base
+
+	if base == nil {
+
+		base = http.DefaultTransport
+
+	}
+
+	r := req.Clone(req.Context())
+	r.URL.Scheme = t.Target.Scheme
+	r.URL.Host = t.Target.Host
+	r.Host = t.Target.Host
+
+	return base.RoundTrip(r)
+
+}
+
+// Return a HTTP client which sends all requests to the server at URL.
+func NewClient(URL string) *http.Client {
+
+	target, err := url.Parse(URL)
+
+	if err != nil {
+
+		panic(err)
+
+	}
+
+	return &http.Client{
+		Transport: &RewriteTransport{Target: target},
+	}
+
+}
+
+// Start a test server (closed when the test ends) and return a client sending all
+// requests to it.
+func NewServer(t testing.TB, h http.Handler) *http.Client {
+
+	srv := httptest.NewServer(h)
+
+	t.Cleanup(srv.Close)
+
+	return NewClient(srv.URL)
+
+}
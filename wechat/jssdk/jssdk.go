@@ -0,0 +1,187 @@
+// Package jssdk supports Wechat JS-SDK: jsapi_ticket/api_ticket caching and
+// wx.config/card signature generation.
+package jssdk
+
+import (
+	"context"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/token"
+	"sync"
+	"time"
+)
+
+// Default value of JSSDK.RefreshAhead.
+const DefaultRefreshAhead = 5 * time.Minute
+
+type TicketType string
+
+const (
+	// For wx.config.
+	TICKET_TYPE_JSAPI TicketType = "jsapi"
+	// For card APIs (wx.addCard/wx.chooseCard).
+	TICKET_TYPE_WX_CARD TicketType = "wx_card"
+)
+
+type ticket struct {
+	value     string
+	expiresAt time.Time
+}
+
+// JSSDK caches tickets of an app and signs JS-SDK parameters.
+type JSSDK struct {
+	tokens *token.AccessTokenManager
+
+	// Refresh ticket when it expires within this duration. Default to DefaultRefreshAhead.
+	RefreshAhead time.Duration
+
+	// Source of nonce strings. Default to wx.CryptoNonceSource.
+	NonceSource wx.NonceSource
+
+	// Source of current time. Default to wx.SystemClock.
+	Clock wx.Clock
+
+	mu       sync.Mutex
+	tickets  map[TicketType]*ticket
+	inflight map[TicketType]*ticketCall
+}
+
+// An in-flight ticket fetch.
+type ticketCall struct {
+	done  chan struct{}
+	value string
+	err   error
+}
+
+// Create JSSDK instance using the app access token manager.
+func NewJSSDK(tokens *token.AccessTokenManager) (*JSSDK, error) {
+
+	if tokens == nil {
+
+		return nil, fmt.Errorf("NewJSSDK: tokens missing")
+
+	}
+
+	return &JSSDK{
+		tokens:   tokens,
+		tickets:  make(map[TicketType]*ticket),
+		inflight: make(map[TicketType]*ticketCall),
+	}, nil
+
+}
+
+type ticketResult struct {
+	wx.ResultBase
+
+	Ticket    string `json:"ticket"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// Return a valid ticket of the type, fetch it if not cached or about to expire.
+// Concurrent fetches of the same type are deduplicated. The shared fetch runs on
+// its own context (bounded by wx.DefaultAPITimeout) so that a canceled caller
+// does not fail the others; each caller only gives up waiting when its own ctx
+// is done.
+func (j *JSSDK) Ticket(ctx context.Context, ticket_type TicketType, l wx.Logger) (string, error) {
+
+	refresh_ahead := j.RefreshAhead
+
+	if refresh_ahead <= 0 {
+
+		refresh_ahead = DefaultRefreshAhead
+
+	}
+
+	j.mu.Lock()
+
+	if t, ok := j.tickets[ticket_type]; ok && wx.Now(j.Clock).Add(refresh_ahead).Before(t.expiresAt) {
+
+		j.mu.Unlock()
+
+		return t.value, nil
+
+	}
+
+	c := j.inflight[ticket_type]
+
+	if c == nil {
+
+		c = &ticketCall{done: make(chan struct{})}
+
+		j.inflight[ticket_type] = c
+
+		go j.doCall(c, ticket_type, l)
+
+	}
+
+	j.mu.Unlock()
+
+	select {
+
+	case <-c.done:
+
+		return c.value, c.err
+
+	case <-ctx.Done():
+
+		return "", ctx.Err()
+
+	}
+
+}
+
+func (j *JSSDK) doCall(c *ticketCall, ticket_type TicketType, l wx.Logger) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), wx.DefaultAPITimeout)
+
+	defer cancel()
+
+	t, err := j.fetch(ctx, ticket_type, l)
+
+	j.mu.Lock()
+
+	if err == nil {
+
+		j.tickets[ticket_type] = t
+
+		c.value = t.value
+
+	}
+
+	c.err = err
+
+	delete(j.inflight, ticket_type)
+
+	j.mu.Unlock()
+
+	close(c.done)
+
+}
+
+// Fetch a new ticket from Wechat.
+func (j *JSSDK) fetch(ctx context.Context, ticket_type TicketType, l wx.Logger) (*ticket, error) {
+
+	now := wx.Now(j.Clock)
+
+	r := &ticketResult{}
+
+	if err := j.tokens.CallAPI(ctx,
+		"https://api.weixin.qq.com/cgi-bin/ticket/getticket?type="+string(ticket_type),
+		nil, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	if r.Ticket == "" {
+
+		return nil, fmt.Errorf("Ticket: empty ticket")
+
+	}
+
+	return &ticket{
+		value:     r.Ticket,
+		expiresAt: now.Add(time.Duration(r.ExpiresIn) * time.Second),
+	}, nil
+
+}
@@ -0,0 +1,122 @@
+package jssdk
+
+import (
+	"context"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/internal/wxtest"
+	"github.com/huangjunwen/WechatDriver/wechat/token"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Concurrent fetches are deduplicated per ticket type, and do not block each other.
+func TestTicketDeduplicated(t *testing.T) {
+
+	var fetches int32
+
+	jsapi_release := make(chan struct{})
+
+	client := wxtest.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Path == "/cgi-bin/token" {
+
+			fmt.Fprint(w, `{"access_token":"T","expires_in":7200}`)
+
+			return
+
+		}
+
+		atomic.AddInt32(&fetches, 1)
+
+		ticket_type := r.URL.Query().Get("type")
+
+		if ticket_type == string(TICKET_TYPE_JSAPI) {
+
+			<-jsapi_release
+
+		}
+
+		fmt.Fprintf(w, `{"errcode":0,"errmsg":"ok","ticket":"%s_ticket","expires_in":7200}`, ticket_type)
+
+	}))
+
+	tokens, err := token.NewAccessTokenManager(&wx.AppConfig{AppID: "app", AppSecret: "secret"}, client)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	j, err := NewJSSDK(tokens)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			ticket, err := j.Ticket(context.Background(), TICKET_TYPE_JSAPI, nil)
+
+			if err != nil {
+
+				t.Error(err)
+
+				return
+
+			}
+
+			if ticket != "jsapi_ticket" {
+
+				t.Errorf("unexpected ticket %+q", ticket)
+
+			}
+
+		}()
+
+	}
+
+	// Another type is not blocked by the pending jsapi fetch.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	ticket, err := j.Ticket(ctx, TICKET_TYPE_WX_CARD, nil)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if ticket != "wx_card_ticket" {
+
+		t.Fatalf("unexpected ticket %+q", ticket)
+
+	}
+
+	close(jsapi_release)
+
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+
+		t.Fatalf("expect 2 fetches, got %d", n)
+
+	}
+
+}
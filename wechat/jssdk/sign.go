@@ -0,0 +1,173 @@
+package jssdk
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"sort"
+	"strings"
+)
+
+// Parameters of wx.config (besides debug/jsApiList).
+type Config struct {
+	AppID     string `json:"appId"`
+	Timestamp int64  `json:"timestamp"`
+	NonceStr  string `json:"nonceStr"`
+	Signature string `json:"signature"`
+}
+
+// Signature for wx.config: sha1 of
+// "jsapi_ticket=...&noncestr=...&timestamp=...&url=..." in hex. The fragment
+// ("#...") of url is stripped.
+func SignJSAPI(jsapi_ticket, noncestr string, timestamp int64, url string) string {
+
+	if i := strings.IndexByte(url, '#'); i >= 0 {
+
+		url = url[:i]
+
+	}
+
+	s := fmt.Sprintf("jsapi_ticket=%s&noncestr=%s&timestamp=%d&url=%s", jsapi_ticket, noncestr, timestamp, url)
+
+	return fmt.Sprintf("%x", sha1.Sum([]byte(s)))
+
+}
+
+// Generate wx.config parameters for the page at url.
+func (j *JSSDK) SignURL(ctx context.Context, url string, l wx.Logger) (*Config, error) {
+
+	ticket, err := j.Ticket(ctx, TICKET_TYPE_JSAPI, l)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	nonce_str, err := wx.NonceStr(j.NonceSource, 16)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	timestamp := wx.Now(j.Clock).Unix()
+
+	return &Config{
+		AppID:     j.tokens.Config().AppID,
+		Timestamp: timestamp,
+		NonceStr:  nonce_str,
+		Signature: SignJSAPI(ticket, nonce_str, timestamp, url),
+	}, nil
+
+}
+
+// Signature for card APIs: sort all values in dictionary order, concatenate them
+// and take the sha1 in hex. Empty values are skipped.
+func SignCard(values ...string) string {
+
+	vs := make([]string, 0, len(values))
+
+	for _, v := range values {
+
+		if v != "" {
+
+			vs = append(vs, v)
+
+		}
+
+	}
+
+	sort.Strings(vs)
+
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(vs, ""))))
+
+}
+
+// The cardExt (JSON encoded) of wx.addCard.
+type CardExt struct {
+	Code      string `json:"code,omitempty"`
+	OpenID    string `json:"openid,omitempty"`
+	Timestamp string `json:"timestamp"`
+	NonceStr  string `json:"nonce_str"`
+	Signature string `json:"signature"`
+}
+
+// Generate cardExt for wx.addCard. code/openid are optional.
+func (j *JSSDK) SignAddCard(ctx context.Context, card_id, code, openid string, l wx.Logger) (*CardExt, error) {
+
+	api_ticket, err := j.Ticket(ctx, TICKET_TYPE_WX_CARD, l)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	nonce_str, err := wx.NonceStr(j.NonceSource, 16)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	timestamp := fmt.Sprint(wx.Now(j.Clock).Unix())
+
+	return &CardExt{
+		Code:      code,
+		OpenID:    openid,
+		Timestamp: timestamp,
+		NonceStr:  nonce_str,
+		Signature: SignCard(api_ticket, timestamp, card_id, code, openid, nonce_str),
+	}, nil
+
+}
+
+// Parameters of wx.chooseCard.
+type ChooseCardConfig struct {
+	ShopID    string `json:"shopId"`
+	CardType  string `json:"cardType"`
+	CardID    string `json:"cardId"`
+	Timestamp int64  `json:"timestamp"`
+	NonceStr  string `json:"nonceStr"`
+	SignType  string `json:"signType"`
+	CardSign  string `json:"cardSign"`
+}
+
+// Generate parameters for wx.chooseCard. shop_id/card_type/card_id are optional filters.
+func (j *JSSDK) SignChooseCard(ctx context.Context, shop_id, card_type, card_id string, l wx.Logger) (
+	*ChooseCardConfig, error) {
+
+	api_ticket, err := j.Ticket(ctx, TICKET_TYPE_WX_CARD, l)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	nonce_str, err := wx.NonceStr(j.NonceSource, 16)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	timestamp := wx.Now(j.Clock).Unix()
+
+	return &ChooseCardConfig{
+		ShopID:    shop_id,
+		CardType:  card_type,
+		CardID:    card_id,
+		Timestamp: timestamp,
+		NonceStr:  nonce_str,
+		SignType:  "SHA1",
+		CardSign: SignCard(api_ticket, j.tokens.Config().AppID, shop_id, fmt.Sprint(timestamp),
+			nonce_str, card_id, card_type),
+	}, nil
+
+}
@@ -0,0 +1,208 @@
+package miniprogram
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Watermark embedded in decrypted data.
+type Watermark struct {
+	AppID     string `json:"appid"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Decrypted user info (wx.getUserInfo).
+type UserInfo struct {
+	OpenID    string    `json:"openId"`
+	UnionID   string    `json:"unionId"`
+	NickName  string    `json:"nickName"`
+	Gender    int       `json:"gender"`
+	City      string    `json:"city"`
+	Province  string    `json:"province"`
+	Country   string    `json:"country"`
+	AvatarURL string    `json:"avatarUrl"`
+	Language  string    `json:"language"`
+	Watermark Watermark `json:"watermark"`
+}
+
+// Decrypted phone number (getPhoneNumber button).
+type PhoneNumber struct {
+	// Phone number with country code for foreign numbers.
+	PhoneNumber string `json:"phoneNumber"`
+	// Phone number without country code.
+	PurePhoneNumber string    `json:"purePhoneNumber"`
+	CountryCode     string    `json:"countryCode"`
+	Watermark       Watermark `json:"watermark"`
+}
+
+// Decrypted share ticket info (wx.getShareInfo).
+type ShareInfo struct {
+	// Unique id of the group chat.
+	OpenGID   string    `json:"openGId"`
+	Watermark Watermark `json:"watermark"`
+}
+
+// Check the signature of rawData returned by wx.getUserInfo:
+// signature == sha1(rawData + session_key).
+func CheckSignature(raw_data, session_key, signature string) bool {
+
+	sum := sha1.Sum([]byte(raw_data + session_key))
+
+	expect := fmt.Sprintf("%x", sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(expect), []byte(signature)) == 1
+
+}
+
+// Decrypt encryptedData (AES-128-CBC with session_key as key and iv, all base64
+// encoded) into plain text.
+func DecryptData(session_key, encrypted_data, iv string) ([]byte, error) {
+
+	key, err := base64.StdEncoding.DecodeString(session_key)
+
+	if err != nil {
+
+		return nil, fmt.Errorf("DecryptData: bad session_key: %s", err)
+
+	}
+
+	iv_bytes, err := base64.StdEncoding.DecodeString(iv)
+
+	if err != nil {
+
+		return nil, fmt.Errorf("DecryptData: bad iv: %s", err)
+
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encrypted_data)
+
+	if err != nil {
+
+		return nil, fmt.Errorf("DecryptData: bad encrypted_data: %s", err)
+
+	}
+
+	if len(key) != 16 || len(iv_bytes) != aes.BlockSize {
+
+		return nil, fmt.Errorf("DecryptData: bad session_key/iv length")
+
+	}
+
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+
+		return nil, fmt.Errorf("DecryptData: bad encrypted_data length")
+
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	plain := make([]byte, len(data))
+
+	cipher.NewCBCDecrypter(block, iv_bytes).CryptBlocks(plain, data)
+
+	// PKCS#7 unpadding.
+	pad := int(plain[len(plain)-1])
+
+	if pad == 0 || pad > aes.BlockSize {
+
+		return nil, fmt.Errorf("DecryptData: bad padding")
+
+	}
+
+	for _, b := range plain[len(plain)-pad:] {
+
+		if int(b) != pad {
+
+			return nil, fmt.Errorf("DecryptData: bad padding")
+
+		}
+
+	}
+
+	return plain[:len(plain)-pad], nil
+
+}
+
+// Decrypt encryptedData into result (a pointer to struct) and check its watermark.
+func (mp *MiniProgram) decrypt(session_key, encrypted_data, iv string, result interface{},
+	watermark *Watermark) error {
+
+	plain, err := DecryptData(session_key, encrypted_data, iv)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	if err := json.Unmarshal(plain, result); err != nil {
+
+		return err
+
+	}
+
+	if watermark.AppID != mp.config.AppID {
+
+		return fmt.Errorf("Watermark appid mismatch %+q", watermark.AppID)
+
+	}
+
+	return nil
+
+}
+
+// Decrypt user info from wx.getUserInfo.
+func (mp *MiniProgram) DecryptUserInfo(session_key, encrypted_data, iv string) (*UserInfo, error) {
+
+	r := &UserInfo{}
+
+	if err := mp.decrypt(session_key, encrypted_data, iv, r, &r.Watermark); err != nil {
+
+		return nil, err
+
+	}
+
+	return r, nil
+
+}
+
+// Decrypt phone number from the getPhoneNumber button.
+func (mp *MiniProgram) DecryptPhoneNumber(session_key, encrypted_data, iv string) (*PhoneNumber, error) {
+
+	r := &PhoneNumber{}
+
+	if err := mp.decrypt(session_key, encrypted_data, iv, r, &r.Watermark); err != nil {
+
+		return nil, err
+
+	}
+
+	return r, nil
+
+}
+
+// Decrypt share info from wx.getShareInfo.
+func (mp *MiniProgram) DecryptShareInfo(session_key, encrypted_data, iv string) (*ShareInfo, error) {
+
+	r := &ShareInfo{}
+
+	if err := mp.decrypt(session_key, encrypted_data, iv, r, &r.Watermark); err != nil {
+
+		return nil, err
+
+	}
+
+	return r, nil
+
+}
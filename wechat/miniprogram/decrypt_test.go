@@ -0,0 +1,174 @@
+package miniprogram
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/token"
+	"testing"
+)
+
+// The official sample of Wechat's decrypt demo.
+const (
+	sampleAppID         = "wx4f4bc4dec97d474b"
+	sampleSessionKey    = "tiihtNczf5v6AKRyjwEUhQ=="
+	sampleIV            = "r7BXXKkLb8qrSNn05n0qiA=="
+	sampleEncryptedData = "CiyLU1Aw2KjvrjMdj8YKliAjtP4gsMZMQmRzooG2xrDcvSnxIMXFufNstNGTyaGS9uT5geRa0W4oTOb1WT7fJlAC+oNPdbB+3hVbJSRgv+4lGOETKUQz6OYStslQ142dNCuabNPGBzlooOmB231qMM85d2/fV6ChevvXvQP8Hkue1poOFtnEtpyxVLW1zAo6/1Xx1COxFvrc2d7UL/lmHInNlxuacJXwu0fjpXfz/YqYzBIBzD6WUfTIF9GRHpOn/Hz7saL8xz+W//FRAUid1OksQaQx4CMs8LOddcQhULW4ucetDf96JcR3g0gfRK4PC7E/r7Z6xNrXd2UIeorGj5Ef7b1pJAYB6Y5anaHqZ9J6nKEBvB4DnNLIVWSgARns/8wR2SiRS7MNACwTyrGvt9ts8p12PKFdlqYTopNHR1Vf7XjfhQlVsAJdNiKdYmYVoKlaRv85IfVunYzO0IKXsyl7JCUjCpoG20f0a04COwfneQAGGwd5oa+T8yO5hzuyDb/XcxxmK01EpqOyuxINew=="
+)
+
+func newTestMiniProgram(t *testing.T, appid string) *MiniProgram {
+
+	tokens, err := token.NewAccessTokenManager(&wx.AppConfig{AppID: appid, AppSecret: "secret"}, nil)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	mp, err := NewMiniProgram(tokens)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	return mp
+
+}
+
+// Encrypt plain (already padded) with the sample session key and iv.
+func encryptSample(t *testing.T, plain []byte) string {
+
+	key, _ := base64.StdEncoding.DecodeString(sampleSessionKey)
+
+	iv, _ := base64.StdEncoding.DecodeString(sampleIV)
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	data := make([]byte, len(plain))
+
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, plain)
+
+	return base64.StdEncoding.EncodeToString(data)
+
+}
+
+func TestDecryptUserInfo(t *testing.T) {
+
+	r, err := newTestMiniProgram(t, sampleAppID).DecryptUserInfo(sampleSessionKey, sampleEncryptedData, sampleIV)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if r.OpenID != "oGZUI0egBJY1zhBYw2KhdUfwVJJE" || r.UnionID != "ocMvos6NjeKLIBqg5Mr9QjxrP1FA" ||
+		r.NickName != "Band" || r.Gender != 1 || r.City != "Guangzhou" ||
+		r.Watermark.AppID != sampleAppID || r.Watermark.Timestamp != 1477314187 {
+
+		t.Fatalf("unexpected user info %+v", r)
+
+	}
+
+	// Watermark of another app.
+	if _, err := newTestMiniProgram(t, "wxother").DecryptUserInfo(sampleSessionKey, sampleEncryptedData,
+		sampleIV); err == nil {
+
+		t.Fatal("expect error for watermark appid mismatch")
+
+	}
+
+}
+
+func TestDecryptData(t *testing.T) {
+
+	block := func(last ...byte) []byte {
+
+		b := []byte("0123456789abcdef")
+
+		copy(b[len(b)-len(last):], last)
+
+		return b
+
+	}
+
+	for _, c := range []struct {
+		Name          string
+		SessionKey    string
+		EncryptedData string
+		IV            string
+		Expect        string
+		OK            bool
+	}{
+		{"pad_1", sampleSessionKey, encryptSample(t, block(1)), sampleIV, "0123456789abcde", true},
+		{"pad_16", sampleSessionKey, encryptSample(t, append(block(), block(
+			16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16)...)), sampleIV, "0123456789abcdef", true},
+		{"pad_0", sampleSessionKey, encryptSample(t, block(0)), sampleIV, "", false},
+		{"pad_17", sampleSessionKey, encryptSample(t, block(17)), sampleIV, "", false},
+		{"pad_mismatch", sampleSessionKey, encryptSample(t, block(1, 3, 3)), sampleIV, "", false},
+		{"bad_base64", sampleSessionKey, "!!!", sampleIV, "", false},
+		{"bad_length", sampleSessionKey, base64.StdEncoding.EncodeToString([]byte("short")), sampleIV, "", false},
+		{"empty", sampleSessionKey, "", sampleIV, "", false},
+		{"bad_key_length", "MTIz", sampleEncryptedData, sampleIV, "", false},
+		{"bad_iv_length", sampleSessionKey, sampleEncryptedData, "MTIz", "", false},
+	} {
+
+		plain, err := DecryptData(c.SessionKey, c.EncryptedData, c.IV)
+
+		if (err == nil) != c.OK {
+
+			t.Errorf("%s: expect ok=%v, got %v", c.Name, c.OK, err)
+
+			continue
+
+		}
+
+		if c.OK && string(plain) != c.Expect {
+
+			t.Errorf("%s: expect %+q, got %+q", c.Name, c.Expect, plain)
+
+		}
+
+	}
+
+}
+
+func TestCheckSignature(t *testing.T) {
+
+	raw_data := `{"nickName":"Band","gender":1}`
+
+	// sha1(raw_data + session_key)
+	signature := "209fbe7aa3d83ad61a1f56e4fe8d84dd1373991c"
+
+	if !CheckSignature(raw_data, sampleSessionKey, signature) {
+
+		t.Fatal("expect signature ok")
+
+	}
+
+	for _, c := range [][3]string{
+		{raw_data + " ", sampleSessionKey, signature},
+		{raw_data, "AAAAAAAAAAAAAAAAAAAAAA==", signature},
+		{raw_data, sampleSessionKey, "209FBE7AA3D83AD61A1F56E4FE8D84DD1373991C"},
+		{raw_data, sampleSessionKey, ""},
+	} {
+
+		if CheckSignature(c[0], c[1], c[2]) {
+
+			t.Errorf("expect signature mismatch for %+q", c)
+
+		}
+
+	}
+
+}
@@ -0,0 +1,67 @@
+package miniprogram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"net/http"
+)
+
+func (mp *MiniProgram) maxResultSize() int {
+
+	max_result_size := mp.MaxResultSize
+
+	if max_result_size <= 0 {
+
+		max_result_size = 4 * 1024
+
+	}
+
+	return max_result_size
+
+}
+
+func (mp *MiniProgram) callGetAPI(ctx context.Context, URL string, result interface{}, l wx.Logger) (err error) {
+
+	var (
+		req  *http.Request
+		resp *http.Response
+		body bytes.Buffer
+	)
+
+	if l != nil {
+
+		l.Printf("method=\"GET\" url=%+q\n", URL)
+
+	}
+
+	if req, err = http.NewRequest("GET", URL, nil); err != nil {
+
+		return
+
+	}
+
+	req = req.WithContext(ctx)
+
+	if resp, err = mp.client.Do(req); err != nil {
+
+		return
+
+	}
+
+	if err = wx.ReadResponse(resp, &body, int64(mp.maxResultSize()), wx.JSONContentTypes...); err != nil {
+
+		return
+
+	}
+
+	if l != nil {
+
+		l.Printf("status=%+q proto=%+q body=%+q\n", resp.Status, resp.Proto, body.Bytes())
+
+	}
+
+	return json.NewDecoder(&body).Decode(result)
+
+}
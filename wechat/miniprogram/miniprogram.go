@@ -0,0 +1,57 @@
+package miniprogram
+
+import (
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/token"
+	"net/http"
+)
+
+// Communicate to Wechat's mini program service.
+type MiniProgram struct {
+	// The app configuration.
+	config *wx.AppConfig
+
+	// The client to do http API call.
+	client *http.Client
+
+	// The app access token manager.
+	tokens *token.AccessTokenManager
+
+	// Max size when reading incoming result. Default to 4k.
+	MaxResultSize int
+}
+
+// Create MiniProgram instance using the app access token manager of the mini
+// program, its config and HTTP client are used as well. Share the manager with
+// other packages (e.g. subscribe): managers of the same appid invalidate each
+// other's tokens when refreshing.
+//
+// NOTE: the manager is required even for login only use (Code2Session and the
+// Decrypt* methods, which do not need access token): creating one does not touch
+// the network, its token is fetched on first API call needing it.
+func NewMiniProgram(tokens *token.AccessTokenManager) (*MiniProgram, error) {
+
+	if tokens == nil {
+
+		return nil, fmt.Errorf("NewMiniProgram: tokens missing")
+
+	}
+
+	return &MiniProgram{
+		config: tokens.Config(),
+		client: tokens.Client(),
+		tokens: tokens,
+	}, nil
+
+}
+
+// Return the config associated.
+func (mp *MiniProgram) Config() *wx.AppConfig {
+	return mp.config
+}
+
+// Return the app access token manager associated.
+func (mp *MiniProgram) Tokens() *token.AccessTokenManager {
+	return mp.tokens
+}
@@ -0,0 +1,37 @@
+package miniprogram
+
+import (
+	"context"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"net/url"
+)
+
+// The result of MiniProgram.Code2Session
+type SessionResult struct {
+	ResultBase
+
+	OpenID     string `json:"openid"`
+	SessionKey string `json:"session_key"`
+	UnionID    string `json:"unionid"`
+}
+
+// Exchange the code from wx.login for session_key/openid/unionid (sns/jscode2session).
+// Return error only when there is error in transport. Caller should also check r.Error().
+func (mp *MiniProgram) Code2Session(ctx context.Context, js_code string, l wx.Logger) (
+	*SessionResult, error) {
+
+	r := &SessionResult{}
+
+	if err := mp.callGetAPI(ctx,
+		"https://api.weixin.qq.com/sns/jscode2session?"+url.Values{
+			"appid":      []string{mp.config.AppID},
+			"secret":     []string{mp.config.AppSecret},
+			"js_code":    []string{js_code},
+			"grant_type": []string{"authorization_code"},
+		}.Encode(), r, l); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+
+}
@@ -0,0 +1,11 @@
+package miniprogram
+
+import (
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+)
+
+// Common part of mini program API result. Its Error() returns *wx.APIError if
+// errcode != 0.
+type ResultBase struct {
+	wx.ResultBase
+}
@@ -0,0 +1,94 @@
+package miniprogram
+
+import (
+	"context"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+)
+
+type ExpireType int
+
+const (
+	// Expire at ExpireTime.
+	EXPIRE_TYPE_TIME ExpireType = 0
+	// Expire after ExpireInterval days.
+	EXPIRE_TYPE_INTERVAL ExpireType = 1
+)
+
+// Expiration of URL schemes/links.
+type LinkExpire struct {
+	IsExpire       bool       `json:"is_expire"`
+	ExpireType     ExpireType `json:"expire_type"`
+	ExpireTime     int64      `json:"expire_time,omitempty"`     // Unix timestamp
+	ExpireInterval int        `json:"expire_interval,omitempty"` // Days
+}
+
+// The page to open.
+type JumpWxa struct {
+	Path       string     `json:"path"`
+	Query      string     `json:"query,omitempty"`
+	EnvVersion EnvVersion `json:"env_version,omitempty"`
+}
+
+type generateSchemeRequest struct {
+	JumpWxa *JumpWxa `json:"jump_wxa,omitempty"`
+	LinkExpire
+}
+
+type generateSchemeResult struct {
+	wx.ResultBase
+
+	OpenLink string `json:"openlink"`
+}
+
+// Generate an URL scheme ("weixin://dl/business/?t=...") to open the mini program
+// from outside of Wechat. jump is optional (nil for home page).
+func (mp *MiniProgram) GenerateURLScheme(ctx context.Context, jump *JumpWxa, expire LinkExpire,
+	l wx.Logger) (string, error) {
+
+	r := &generateSchemeResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/wxa/generatescheme",
+		&generateSchemeRequest{
+			JumpWxa:    jump,
+			LinkExpire: expire,
+		}, r, l); err != nil {
+
+		return "", err
+
+	}
+
+	return r.OpenLink, nil
+
+}
+
+type generateURLLinkRequest struct {
+	JumpWxa
+	LinkExpire
+}
+
+type generateURLLinkResult struct {
+	wx.ResultBase
+
+	URLLink string `json:"url_link"`
+}
+
+// Generate an URL link ("https://wxaurl.cn/...") to open the mini program from
+// outside of Wechat. Empty path means home page.
+func (mp *MiniProgram) GenerateURLLink(ctx context.Context, jump JumpWxa, expire LinkExpire,
+	l wx.Logger) (string, error) {
+
+	r := &generateURLLinkResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/wxa/generate_urllink",
+		&generateURLLinkRequest{
+			JumpWxa:    jump,
+			LinkExpire: expire,
+		}, r, l); err != nil {
+
+		return "", err
+
+	}
+
+	return r.URLLink, nil
+
+}
@@ -0,0 +1,162 @@
+package miniprogram
+
+import (
+	"context"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"io"
+	"regexp"
+)
+
+// Limits of mini program codes.
+const (
+	MaxWxaCodePathBytes  = 128
+	MaxWxaCodeSceneChars = 32
+)
+
+// Characters allowed in scene of getwxacodeunlimit.
+var sceneRegexp = regexp.MustCompile(`^[0-9A-Za-z!#$&'()*+,/:;=?@\-._~]+$`)
+
+// Version of the mini program to open.
+type EnvVersion string
+
+const (
+	ENV_VERSION_RELEASE EnvVersion = "release"
+	ENV_VERSION_TRIAL   EnvVersion = "trial"
+	ENV_VERSION_DEVELOP EnvVersion = "develop"
+)
+
+type LineColor struct {
+	R int `json:"r"`
+	G int `json:"g"`
+	B int `json:"b"`
+}
+
+// Common options of mini program codes.
+type WxaCodeOptions struct {
+	// Width in px, default to 430 by Wechat (280~1280).
+	Width int `json:"width,omitempty"`
+	// Use the dominant color of the logo as line color.
+	AutoColor bool       `json:"auto_color,omitempty"`
+	LineColor *LineColor `json:"line_color,omitempty"` // When AutoColor is false
+	// Transparent background.
+	IsHyaline  bool       `json:"is_hyaline,omitempty"`
+	EnvVersion EnvVersion `json:"env_version,omitempty"`
+}
+
+// Info of the image written.
+type WxaCodeImage struct {
+	ContentType string
+}
+
+type getWxaCodeRequest struct {
+	Path string `json:"path"`
+	WxaCodeOptions
+}
+
+// Generate a mini program code with path (with query, ≤ 128 bytes), the total
+// number of codes is limited (100,000). The image is streamed into w, return
+// *wx.APIError if Wechat responds with an error.
+func (mp *MiniProgram) GetWxaCode(ctx context.Context, path string, opts *WxaCodeOptions, w io.Writer,
+	l wx.Logger) (*WxaCodeImage, error) {
+
+	if path == "" || len(path) > MaxWxaCodePathBytes {
+
+		return nil, fmt.Errorf("GetWxaCode: path should be 1~%d bytes", MaxWxaCodePathBytes)
+
+	}
+
+	req := &getWxaCodeRequest{Path: path}
+
+	if opts != nil {
+
+		req.WxaCodeOptions = *opts
+
+	}
+
+	return mp.callImageAPI(ctx, "https://api.weixin.qq.com/wxa/getwxacode", req, w, l)
+
+}
+
+type getWxaCodeUnlimitRequest struct {
+	Scene     string `json:"scene"`
+	Page      string `json:"page,omitempty"`
+	CheckPath *bool  `json:"check_path,omitempty"`
+	WxaCodeOptions
+}
+
+// Options of GetWxaCodeUnlimit.
+type WxaCodeUnlimitOptions struct {
+	WxaCodeOptions
+	// Page without query, default to the home page.
+	Page string
+	// Set false to allow pages not yet published, default true by Wechat.
+	CheckPath *bool
+}
+
+// Generate a mini program code with scene (≤ 32 chars), which is passed to the
+// page as query "scene", the number of codes is unlimited. The image is streamed
+// into w, return *wx.APIError if Wechat responds with an error.
+func (mp *MiniProgram) GetWxaCodeUnlimit(ctx context.Context, scene string, opts *WxaCodeUnlimitOptions,
+	w io.Writer, l wx.Logger) (*WxaCodeImage, error) {
+
+	if len(scene) > MaxWxaCodeSceneChars || !sceneRegexp.MatchString(scene) {
+
+		return nil, fmt.Errorf("GetWxaCodeUnlimit: scene should be 1~%d chars of digits, letters or !#$&'()*+,/:;=?@-._~",
+			MaxWxaCodeSceneChars)
+
+	}
+
+	req := &getWxaCodeUnlimitRequest{Scene: scene}
+
+	if opts != nil {
+
+		req.Page = opts.Page
+
+		req.CheckPath = opts.CheckPath
+
+		req.WxaCodeOptions = opts.WxaCodeOptions
+
+	}
+
+	return mp.callImageAPI(ctx, "https://api.weixin.qq.com/wxa/getwxacodeunlimit", req, w, l)
+
+}
+
+// Generate a mini program QR code (the square one) with path (with query, ≤ 128
+// bytes), the total number is limited as GetWxaCode. width is optional (0).
+func (mp *MiniProgram) CreateWxaQRCode(ctx context.Context, path string, width int, w io.Writer,
+	l wx.Logger) (*WxaCodeImage, error) {
+
+	if path == "" || len(path) > MaxWxaCodePathBytes {
+
+		return nil, fmt.Errorf("CreateWxaQRCode: path should be 1~%d bytes", MaxWxaCodePathBytes)
+
+	}
+
+	return mp.callImageAPI(ctx, "https://api.weixin.qq.com/cgi-bin/wxaapp/createwxaqrcode",
+		&getWxaCodeRequest{
+			Path: path,
+			WxaCodeOptions: WxaCodeOptions{
+				Width: width,
+			},
+		}, w, l)
+
+}
+
+func (mp *MiniProgram) callImageAPI(ctx context.Context, URL string, in interface{}, w io.Writer,
+	l wx.Logger) (*WxaCodeImage, error) {
+
+	header, err := mp.tokens.CallRawAPI(ctx, URL, in, w, nil, l)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	return &WxaCodeImage{
+		ContentType: header.Get("Content-Type"),
+	}, nil
+
+}
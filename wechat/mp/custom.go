@@ -0,0 +1,245 @@
+package mp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+)
+
+// Errcode when the user has not interacted with the account within 48 hours (or
+// has unsubscribed).
+const ERRCODE_OUT_OF_RESPONSE_WINDOW = 45015
+
+// Max number of articles in a custom news message.
+const MaxCustomNewsArticles = 1
+
+// OutOfWindowError is returned by SendCustomMessage when errcode is 45015.
+type OutOfWindowError struct {
+	APIError *wx.APIError
+}
+
+func (e *OutOfWindowError) Error() string {
+
+	return fmt.Sprintf("out of 48h response window: %s", e.APIError)
+
+}
+
+func (e *OutOfWindowError) Unwrap() error {
+	return e.APIError
+}
+
+type CustomMsgType string
+
+const (
+	CUSTOM_MSG_TYPE_TEXT            CustomMsgType = "text"
+	CUSTOM_MSG_TYPE_IMAGE           CustomMsgType = "image"
+	CUSTOM_MSG_TYPE_NEWS            CustomMsgType = "news"
+	CUSTOM_MSG_TYPE_MPNEWS          CustomMsgType = "mpnews"
+	CUSTOM_MSG_TYPE_MENU            CustomMsgType = "msgmenu"
+	CUSTOM_MSG_TYPE_MINIPROGRAMPAGE CustomMsgType = "miniprogrampage"
+)
+
+type CustomText struct {
+	Content string `json:"content"`
+}
+
+type CustomMedia struct {
+	MediaID string `json:"media_id"`
+}
+
+type CustomArticle struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url"`
+	PicURL      string `json:"picurl,omitempty"`
+}
+
+type CustomNews struct {
+	Articles []CustomArticle `json:"articles"`
+}
+
+type CustomMenuItem struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+}
+
+// A menu message, when user clicks an item, a text message with bizmsgmenuid of
+// the item's id is received.
+type CustomMenu struct {
+	HeadContent string           `json:"head_content,omitempty"`
+	List        []CustomMenuItem `json:"list"`
+	TailContent string           `json:"tail_content,omitempty"`
+}
+
+type CustomMiniProgramPage struct {
+	Title        string `json:"title"`
+	AppID        string `json:"appid"`
+	PagePath     string `json:"pagepath"`
+	ThumbMediaID string `json:"thumb_media_id"`
+}
+
+// Send as a specified customer service account.
+type CustomService struct {
+	KfAccount string `json:"kf_account"`
+}
+
+// A customer service message. Exactly one of the payloads matching MsgType should
+// be set, use NewCustomXXX to create one.
+type CustomMessage struct {
+	ToUser  string        `json:"touser"`
+	MsgType CustomMsgType `json:"msgtype"`
+
+	Text            *CustomText            `json:"text,omitempty"`
+	Image           *CustomMedia           `json:"image,omitempty"`
+	News            *CustomNews            `json:"news,omitempty"`
+	MPNews          *CustomMedia           `json:"mpnews,omitempty"`
+	Menu            *CustomMenu            `json:"msgmenu,omitempty"`
+	MiniProgramPage *CustomMiniProgramPage `json:"miniprogrampage,omitempty"`
+
+	// Optional.
+	CustomService *CustomService `json:"customservice,omitempty"`
+}
+
+func NewCustomText(touser, content string) *CustomMessage {
+
+	return &CustomMessage{
+		ToUser:  touser,
+		MsgType: CUSTOM_MSG_TYPE_TEXT,
+		Text:    &CustomText{Content: content},
+	}
+
+}
+
+func NewCustomImage(touser, media_id string) *CustomMessage {
+
+	return &CustomMessage{
+		ToUser:  touser,
+		MsgType: CUSTOM_MSG_TYPE_IMAGE,
+		Image:   &CustomMedia{MediaID: media_id},
+	}
+
+}
+
+func NewCustomNews(touser string, articles ...CustomArticle) *CustomMessage {
+
+	return &CustomMessage{
+		ToUser:  touser,
+		MsgType: CUSTOM_MSG_TYPE_NEWS,
+		News:    &CustomNews{Articles: articles},
+	}
+
+}
+
+// News of a permanent material.
+func NewCustomMPNews(touser, media_id string) *CustomMessage {
+
+	return &CustomMessage{
+		ToUser:  touser,
+		MsgType: CUSTOM_MSG_TYPE_MPNEWS,
+		MPNews:  &CustomMedia{MediaID: media_id},
+	}
+
+}
+
+func NewCustomMenu(touser string, menu *CustomMenu) *CustomMessage {
+
+	return &CustomMessage{
+		ToUser:  touser,
+		MsgType: CUSTOM_MSG_TYPE_MENU,
+		Menu:    menu,
+	}
+
+}
+
+func NewCustomMiniProgramPage(touser string, page *CustomMiniProgramPage) *CustomMessage {
+
+	return &CustomMessage{
+		ToUser:          touser,
+		MsgType:         CUSTOM_MSG_TYPE_MINIPROGRAMPAGE,
+		MiniProgramPage: page,
+	}
+
+}
+
+func (msg *CustomMessage) validate() error {
+
+	if msg.ToUser == "" {
+
+		return fmt.Errorf("CustomMessage: touser is required")
+
+	}
+
+	var ok bool
+
+	switch msg.MsgType {
+
+	case CUSTOM_MSG_TYPE_TEXT:
+		ok = msg.Text != nil && msg.Text.Content != ""
+	case CUSTOM_MSG_TYPE_IMAGE:
+		ok = msg.Image != nil && msg.Image.MediaID != ""
+	case CUSTOM_MSG_TYPE_NEWS:
+		ok = msg.News != nil && len(msg.News.Articles) > 0 && len(msg.News.Articles) <= MaxCustomNewsArticles
+	case CUSTOM_MSG_TYPE_MPNEWS:
+		ok = msg.MPNews != nil && msg.MPNews.MediaID != ""
+	case CUSTOM_MSG_TYPE_MENU:
+		ok = msg.Menu != nil && len(msg.Menu.List) > 0
+	case CUSTOM_MSG_TYPE_MINIPROGRAMPAGE:
+		ok = msg.MiniProgramPage != nil && msg.MiniProgramPage.AppID != "" && msg.MiniProgramPage.PagePath != ""
+	default:
+		return fmt.Errorf("CustomMessage: unknown msgtype %+q", msg.MsgType)
+
+	}
+
+	if !ok {
+
+		return fmt.Errorf("CustomMessage: bad or missing %s payload", msg.MsgType)
+
+	}
+
+	return nil
+
+}
+
+// Send a customer service message, it's only allowed within 48 hours after the
+// user's last interaction, otherwise *OutOfWindowError is returned.
+func (mp *MP) SendCustomMessage(ctx context.Context, msg *CustomMessage, l wx.Logger) error {
+
+	if err := msg.validate(); err != nil {
+
+		return err
+
+	}
+
+	err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/message/custom/send", msg, nil, l)
+
+	var api_err *wx.APIError
+
+	if errors.As(err, &api_err) && api_err.ErrCode == ERRCODE_OUT_OF_RESPONSE_WINDOW {
+
+		return &OutOfWindowError{APIError: api_err}
+
+	}
+
+	return err
+
+}
+
+// Show ("Typing") or hide ("CancelTyping") the typing indicator to the user.
+func (mp *MP) CustomTyping(ctx context.Context, touser string, typing bool, l wx.Logger) error {
+
+	command := "CancelTyping"
+
+	if typing {
+
+		command = "Typing"
+
+	}
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/message/custom/typing",
+		map[string]string{
+			"touser":  touser,
+			"command": command,
+		}, nil, l)
+
+}
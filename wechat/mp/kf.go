@@ -0,0 +1,214 @@
+package mp
+
+import (
+	"context"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"net/url"
+)
+
+// A customer service account, kf_account is in form of "name@account_id".
+type KfAccount struct {
+	KfAccount        string `json:"kf_account"`
+	KfNick           string `json:"kf_nick"`
+	KfID             string `json:"kf_id"`
+	KfHeadImgURL     string `json:"kf_headimgurl"`
+	KfWx             string `json:"kf_wx"`     // Bound wechat id
+	InviteWx         string `json:"invite_wx"` // Invited wechat id, if not bound yet
+	InviteExpireTime int64  `json:"invite_expire_time"`
+	InviteStatus     string `json:"invite_status"` // "waiting", "rejected" or "expired"
+}
+
+// Add a customer service account.
+func (mp *MP) AddKfAccount(ctx context.Context, kf_account, nickname string, l wx.Logger) error {
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/customservice/kfaccount/add",
+		map[string]string{
+			"kf_account": kf_account,
+			"nickname":   nickname,
+		}, nil, l)
+
+}
+
+// Update nickname of a customer service account.
+func (mp *MP) UpdateKfAccount(ctx context.Context, kf_account, nickname string, l wx.Logger) error {
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/customservice/kfaccount/update",
+		map[string]string{
+			"kf_account": kf_account,
+			"nickname":   nickname,
+		}, nil, l)
+
+}
+
+// Delete a customer service account.
+func (mp *MP) DelKfAccount(ctx context.Context, kf_account string, l wx.Logger) error {
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/customservice/kfaccount/del?"+
+		url.Values{"kf_account": []string{kf_account}}.Encode(), nil, nil, l)
+
+}
+
+// Invite a wechat user to bind the customer service account.
+func (mp *MP) InviteKfWorker(ctx context.Context, kf_account, invite_wx string, l wx.Logger) error {
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/customservice/kfaccount/inviteworker",
+		map[string]string{
+			"kf_account": kf_account,
+			"invite_wx":  invite_wx,
+		}, nil, l)
+
+}
+
+type getKfListResult struct {
+	wx.ResultBase
+
+	KfList []KfAccount `json:"kf_list"`
+}
+
+// List all customer service accounts.
+func (mp *MP) GetKfList(ctx context.Context, l wx.Logger) ([]KfAccount, error) {
+
+	r := &getKfListResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/customservice/getkflist",
+		nil, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r.KfList, nil
+
+}
+
+type OnlineKf struct {
+	KfAccount    string `json:"kf_account"`
+	KfID         string `json:"kf_id"`
+	Status       int    `json:"status"` // 1: web online
+	AcceptedCase int    `json:"accepted_case"`
+}
+
+type getOnlineKfListResult struct {
+	wx.ResultBase
+
+	KfOnlineList []OnlineKf `json:"kf_online_list"`
+}
+
+// List online customer service accounts.
+func (mp *MP) GetOnlineKfList(ctx context.Context, l wx.Logger) ([]OnlineKf, error) {
+
+	r := &getOnlineKfListResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/customservice/getonlinekflist",
+		nil, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r.KfOnlineList, nil
+
+}
+
+// --- Sessions
+
+// Create a session between the user and the customer service account.
+func (mp *MP) CreateKfSession(ctx context.Context, kf_account, openid string, l wx.Logger) error {
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/customservice/kfsession/create",
+		map[string]string{
+			"kf_account": kf_account,
+			"openid":     openid,
+		}, nil, l)
+
+}
+
+// Close a session.
+func (mp *MP) CloseKfSession(ctx context.Context, kf_account, openid string, l wx.Logger) error {
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/customservice/kfsession/close",
+		map[string]string{
+			"kf_account": kf_account,
+			"openid":     openid,
+		}, nil, l)
+
+}
+
+type KfSession struct {
+	KfAccount  string `json:"kf_account"` // Empty if no session
+	CreateTime int64  `json:"createtime"`
+}
+
+type getKfSessionResult struct {
+	wx.ResultBase
+	KfSession
+}
+
+// Get the user's current session.
+func (mp *MP) GetKfSession(ctx context.Context, openid string, l wx.Logger) (*KfSession, error) {
+
+	r := &getKfSessionResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/customservice/kfsession/getsession?"+
+		url.Values{"openid": []string{openid}}.Encode(), nil, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return &r.KfSession, nil
+
+}
+
+type KfSessionItem struct {
+	OpenID     string `json:"openid"`
+	CreateTime int64  `json:"createtime"`
+}
+
+type getKfSessionListResult struct {
+	wx.ResultBase
+
+	SessionList []KfSessionItem `json:"sessionlist"`
+}
+
+// List sessions of a customer service account.
+func (mp *MP) GetKfSessionList(ctx context.Context, kf_account string, l wx.Logger) ([]KfSessionItem, error) {
+
+	r := &getKfSessionListResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/customservice/kfsession/getsessionlist?"+
+		url.Values{"kf_account": []string{kf_account}}.Encode(), nil, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r.SessionList, nil
+
+}
+
+type KfWaitCase struct {
+	OpenID     string `json:"openid"`
+	LatestTime int64  `json:"latest_time"`
+}
+
+type KfWaitCaseResult struct {
+	Count        int          `json:"count"`
+	WaitCaseList []KfWaitCase `json:"waitcaselist"`
+}
+
+// List users waiting for a session.
+func (mp *MP) GetKfWaitCase(ctx context.Context, l wx.Logger) (*KfWaitCaseResult, error) {
+
+	r := &KfWaitCaseResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/customservice/kfsession/getwaitcase",
+		nil, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r, nil
+
+}
@@ -0,0 +1,456 @@
+package mp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/token"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Returned (maybe wrapped) when the media exceeds the size limit of its type.
+var ErrMediaTooLarge = errors.New("media too large")
+
+type MediaType string
+
+const (
+	MEDIA_TYPE_IMAGE MediaType = "image"
+	MEDIA_TYPE_VOICE MediaType = "voice"
+	MEDIA_TYPE_VIDEO MediaType = "video"
+	MEDIA_TYPE_THUMB MediaType = "thumb"
+	// Only for BatchGetMaterial.
+	MEDIA_TYPE_NEWS MediaType = "news"
+)
+
+type mediaLimit struct {
+	maxSize int64
+	exts    []string
+}
+
+// Limits of temporary media.
+var mediaLimits = map[MediaType]mediaLimit{
+	MEDIA_TYPE_IMAGE: {10 << 20, []string{".png", ".jpeg", ".jpg", ".gif"}},
+	MEDIA_TYPE_VOICE: {2 << 20, []string{".amr", ".mp3"}},
+	MEDIA_TYPE_VIDEO: {10 << 20, []string{".mp4"}},
+	MEDIA_TYPE_THUMB: {64 << 10, []string{".jpg", ".jpeg"}},
+}
+
+// Limits of permanent media.
+var materialLimits = map[MediaType]mediaLimit{
+	MEDIA_TYPE_IMAGE: {10 << 20, []string{".bmp", ".png", ".jpeg", ".jpg", ".gif"}},
+	MEDIA_TYPE_VOICE: {2 << 20, []string{".mp3", ".wma", ".wav", ".amr"}},
+	MEDIA_TYPE_VIDEO: {10 << 20, []string{".mp4"}},
+	MEDIA_TYPE_THUMB: {64 << 10, []string{".jpg", ".jpeg"}},
+}
+
+// A media file to upload. It's streamed, the size limit is enforced while reading
+// if Size is unknown.
+type Media struct {
+	Type MediaType
+	// Its extension should match Type.
+	FileName string
+	Reader   io.Reader
+	// Size of the file, -1 if unknown.
+	Size int64
+}
+
+func (m *Media) uploadFile(limits map[MediaType]mediaLimit) (*token.UploadFile, error) {
+
+	limit, ok := limits[m.Type]
+
+	if !ok {
+
+		return nil, fmt.Errorf("Media: unsupported type %+q", m.Type)
+
+	}
+
+	ext := strings.ToLower(filepath.Ext(m.FileName))
+
+	ext_ok := false
+
+	for _, e := range limit.exts {
+
+		if ext == e {
+
+			ext_ok = true
+
+			break
+
+		}
+
+	}
+
+	if !ext_ok {
+
+		return nil, fmt.Errorf("Media: %s file should be one of %s but got %+q", m.Type,
+			strings.Join(limit.exts, "/"), m.FileName)
+
+	}
+
+	if m.Size > limit.maxSize {
+
+		return nil, fmt.Errorf("Media: %s %+q: %w (%d > %d)", m.Type, m.FileName, ErrMediaTooLarge,
+			m.Size, limit.maxSize)
+
+	}
+
+	return &token.UploadFile{
+		FileName: m.FileName,
+		Reader: &sizeLimitReader{
+			r:   m.Reader,
+			n:   limit.maxSize,
+			err: fmt.Errorf("Media: %s %+q: %w (> %d)", m.Type, m.FileName, ErrMediaTooLarge, limit.maxSize),
+		},
+	}, nil
+
+}
+
+// Like io.LimitedReader but return err rather than io.EOF when exceeding.
+type sizeLimitReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (r *sizeLimitReader) Read(p []byte) (int, error) {
+
+	if r.n < 0 {
+
+		return 0, r.err
+
+	}
+
+	if int64(len(p)) > r.n+1 {
+
+		p = p[:r.n+1]
+
+	}
+
+	n, err := r.r.Read(p)
+
+	r.n -= int64(n)
+
+	if r.n < 0 {
+
+		return n, r.err
+
+	}
+
+	return n, err
+
+}
+
+// Make it rewindable (see token.CallUploadAPI) if the underlying reader is.
+func (r *sizeLimitReader) Seek(offset int64, whence int) (int64, error) {
+
+	s, ok := r.r.(io.Seeker)
+
+	if !ok {
+
+		return 0, fmt.Errorf("Media: reader is not seekable")
+
+	}
+
+	cur, err := s.Seek(0, io.SeekCurrent)
+
+	if err != nil {
+
+		return 0, err
+
+	}
+
+	pos, err := s.Seek(offset, whence)
+
+	if err != nil {
+
+		return 0, err
+
+	}
+
+	r.n += cur - pos
+
+	return pos, nil
+
+}
+
+// --- Temporary media (kept for 3 days)
+
+type UploadMediaResult struct {
+	Type         MediaType `json:"type"`
+	MediaID      string    `json:"media_id"`
+	ThumbMediaID string    `json:"thumb_media_id"` // For MEDIA_TYPE_THUMB
+	CreatedAt    int64     `json:"created_at"`
+}
+
+// Upload a temporary media.
+func (mp *MP) UploadMedia(ctx context.Context, media *Media, l wx.Logger) (*UploadMediaResult, error) {
+
+	file, err := media.uploadFile(mediaLimits)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	r := &UploadMediaResult{}
+
+	if err := mp.tokens.CallUploadAPI(ctx, "https://api.weixin.qq.com/cgi-bin/media/upload?"+
+		url.Values{"type": []string{string(media.Type)}}.Encode(), nil, file, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r, nil
+
+}
+
+// Info of a downloaded media/material.
+type MediaFile struct {
+	ContentType string
+	// From Content-Disposition.
+	FileName string
+}
+
+func newMediaFile(header http.Header) *MediaFile {
+
+	f := &MediaFile{
+		ContentType: header.Get("Content-Type"),
+	}
+
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+
+		f.FileName = params["filename"]
+
+	}
+
+	return f
+
+}
+
+type getMediaResult struct {
+	VideoURL string `json:"video_url"`
+}
+
+// Download a temporary media into w. For videos, nothing is written and the
+// download URL is returned instead.
+func (mp *MP) GetMedia(ctx context.Context, media_id string, w io.Writer, l wx.Logger) (
+	file *MediaFile, video_url string, err error) {
+
+	r := &getMediaResult{}
+
+	header, err := mp.tokens.CallRawAPI(ctx, "https://api.weixin.qq.com/cgi-bin/media/get?"+
+		url.Values{"media_id": []string{media_id}}.Encode(), nil, w, r, l)
+
+	if err != nil {
+
+		return nil, "", err
+
+	}
+
+	return newMediaFile(header), r.VideoURL, nil
+
+}
+
+// --- Permanent media (materials)
+
+// Description of video materials.
+type VideoDescription struct {
+	Title        string `json:"title"`
+	Introduction string `json:"introduction"`
+}
+
+type AddMaterialResult struct {
+	MediaID string `json:"media_id"`
+	// For images only.
+	URL string `json:"url"`
+}
+
+// Upload a permanent media, video is required for MEDIA_TYPE_VIDEO.
+func (mp *MP) AddMaterial(ctx context.Context, media *Media, video *VideoDescription, l wx.Logger) (
+	*AddMaterialResult, error) {
+
+	file, err := media.uploadFile(materialLimits)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	var fields map[string]string
+
+	if media.Type == MEDIA_TYPE_VIDEO {
+
+		if video == nil || video.Title == "" {
+
+			return nil, fmt.Errorf("AddMaterial: video's title is required")
+
+		}
+
+		description, _ := json.Marshal(video)
+
+		fields = map[string]string{
+			"description": string(description),
+		}
+
+	}
+
+	r := &AddMaterialResult{}
+
+	if err := mp.tokens.CallUploadAPI(ctx, "https://api.weixin.qq.com/cgi-bin/material/add_material?"+
+		url.Values{"type": []string{string(media.Type)}}.Encode(), fields, file, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r, nil
+
+}
+
+// An article of news materials.
+type NewsArticle struct {
+	Title              string `json:"title"`
+	ThumbMediaID       string `json:"thumb_media_id"`
+	ThumbURL           string `json:"thumb_url,omitempty"`
+	ShowCoverPic       int    `json:"show_cover_pic"`
+	Author             string `json:"author"`
+	Digest             string `json:"digest"`
+	Content            string `json:"content"`
+	URL                string `json:"url,omitempty"`
+	ContentSourceURL   string `json:"content_source_url"`
+	NeedOpenComment    int    `json:"need_open_comment"`
+	OnlyFansCanComment int    `json:"only_fans_can_comment"`
+}
+
+// A permanent media. Only one group of fields is set depending on its type.
+type Material struct {
+	// --- Images, voices and thumbs (written into w)
+	File *MediaFile `json:"-"`
+
+	// --- News
+	NewsItems []NewsArticle `json:"news_item"`
+
+	// --- Videos
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	DownURL     string `json:"down_url"`
+}
+
+// Get a permanent media. The content of images, voices and thumbs is written into
+// w, while news and videos are returned in fields.
+func (mp *MP) GetMaterial(ctx context.Context, media_id string, w io.Writer, l wx.Logger) (*Material, error) {
+
+	r := &Material{}
+
+	header, err := mp.tokens.CallRawAPI(ctx, "https://api.weixin.qq.com/cgi-bin/material/get_material",
+		map[string]string{
+			"media_id": media_id,
+		}, w, r, l)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	if r.NewsItems == nil && r.DownURL == "" {
+
+		r.File = newMediaFile(header)
+
+	}
+
+	return r, nil
+
+}
+
+// Delete a permanent media.
+func (mp *MP) DelMaterial(ctx context.Context, media_id string, l wx.Logger) error {
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/material/del_material",
+		map[string]string{
+			"media_id": media_id,
+		}, nil, l)
+
+}
+
+type MaterialCountResult struct {
+	VoiceCount int `json:"voice_count"`
+	VideoCount int `json:"video_count"`
+	ImageCount int `json:"image_count"`
+	NewsCount  int `json:"news_count"`
+}
+
+// Get numbers of permanent media.
+func (mp *MP) GetMaterialCount(ctx context.Context, l wx.Logger) (*MaterialCountResult, error) {
+
+	r := &MaterialCountResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/material/get_materialcount",
+		nil, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r, nil
+
+}
+
+type MaterialNewsContent struct {
+	NewsItems []NewsArticle `json:"news_item"`
+}
+
+type MaterialItem struct {
+	MediaID    string `json:"media_id"`
+	UpdateTime int64  `json:"update_time"`
+
+	// --- Non-news
+	Name string `json:"name"`
+	URL  string `json:"url"`
+
+	// --- News
+	Content *MaterialNewsContent `json:"content"`
+}
+
+type BatchGetMaterialResult struct {
+	TotalCount int            `json:"total_count"`
+	ItemCount  int            `json:"item_count"`
+	Items      []MaterialItem `json:"item"`
+}
+
+// List permanent media of a type, count should be 1~20.
+func (mp *MP) BatchGetMaterial(ctx context.Context, media_type MediaType, offset, count int,
+	l wx.Logger) (*BatchGetMaterialResult, error) {
+
+	if count < 1 || count > 20 {
+
+		return nil, fmt.Errorf("BatchGetMaterial: count should be 1~20")
+
+	}
+
+	r := &BatchGetMaterialResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/material/batchget_material",
+		map[string]interface{}{
+			"type":   media_type,
+			"offset": offset,
+			"count":  count,
+		}, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r, nil
+
+}
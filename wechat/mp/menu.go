@@ -0,0 +1,323 @@
+package mp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"io"
+)
+
+// Limits of menus.
+const (
+	MaxMenuButtons      = 3
+	MaxMenuSubButtons   = 5
+	MaxMenuNameBytes    = 16 // Top-level button
+	MaxMenuSubNameBytes = 60 // Sub button
+	MaxMenuKeyBytes     = 128
+	MaxMenuURLBytes     = 1024
+)
+
+// Errcode when menu does not exist.
+const ERRCODE_MENU_NOT_EXIST = 46003
+
+type ButtonType string
+
+const (
+	BUTTON_TYPE_CLICK              ButtonType = "click"
+	BUTTON_TYPE_VIEW               ButtonType = "view"
+	BUTTON_TYPE_MINIPROGRAM        ButtonType = "miniprogram"
+	BUTTON_TYPE_SCANCODE_PUSH      ButtonType = "scancode_push"
+	BUTTON_TYPE_SCANCODE_WAITMSG   ButtonType = "scancode_waitmsg"
+	BUTTON_TYPE_PIC_SYSPHOTO       ButtonType = "pic_sysphoto"
+	BUTTON_TYPE_PIC_PHOTO_OR_ALBUM ButtonType = "pic_photo_or_album"
+	BUTTON_TYPE_PIC_WEIXIN         ButtonType = "pic_weixin"
+	BUTTON_TYPE_LOCATION_SELECT    ButtonType = "location_select"
+	BUTTON_TYPE_MEDIA_ID           ButtonType = "media_id"
+	BUTTON_TYPE_VIEW_LIMITED       ButtonType = "view_limited"
+	BUTTON_TYPE_ARTICLE_ID         ButtonType = "article_id"
+	BUTTON_TYPE_ARTICLE_VIEW_LIMIT ButtonType = "article_view_limited"
+)
+
+// A menu button. A top-level button either has SubButtons (then Type is ignored)
+// or is a leaf button of Type. The yaml tags are for loading definitions with a
+// yaml library (see package menuyaml).
+type Button struct {
+	Type       ButtonType `json:"type,omitempty" yaml:"type,omitempty"`
+	Name       string     `json:"name" yaml:"name"`
+	Key        string     `json:"key,omitempty" yaml:"key,omitempty"`               // For click, scancode_*, pic_*, location_select
+	URL        string     `json:"url,omitempty" yaml:"url,omitempty"`               // For view, miniprogram (fallback url)
+	MediaID    string     `json:"media_id,omitempty" yaml:"media_id,omitempty"`     // For media_id, view_limited
+	AppID      string     `json:"appid,omitempty" yaml:"appid,omitempty"`           // For miniprogram
+	PagePath   string     `json:"pagepath,omitempty" yaml:"pagepath,omitempty"`     // For miniprogram
+	ArticleID  string     `json:"article_id,omitempty" yaml:"article_id,omitempty"` // For article_id, article_view_limited
+	SubButtons []Button   `json:"sub_button,omitempty" yaml:"sub_button,omitempty"`
+}
+
+// Rule of conditional menus, at least one field should be set.
+type MatchRule struct {
+	TagID string `json:"tag_id,omitempty" yaml:"tag_id,omitempty"`
+	// "1": iOS, "2": Android, "3": others
+	ClientPlatformType string `json:"client_platform_type,omitempty" yaml:"client_platform_type,omitempty"`
+}
+
+type Menu struct {
+	Buttons []Button `json:"button" yaml:"button"`
+	// Only for conditional menus.
+	MatchRule *MatchRule `json:"matchrule,omitempty" yaml:"matchrule,omitempty"`
+	// Returned by Wechat.
+	MenuID int64 `json:"menuid,omitempty" yaml:"-"`
+}
+
+// Load a menu definition in JSON (the same format as menu/create). See package
+// menuyaml for YAML.
+func LoadMenu(r io.Reader) (*Menu, error) {
+
+	menu := &Menu{}
+
+	if err := json.NewDecoder(r).Decode(menu); err != nil {
+
+		return nil, err
+
+	}
+
+	return menu, nil
+
+}
+
+// Validate buttons against Wechat's limits.
+func (m *Menu) Validate() error {
+
+	if len(m.Buttons) == 0 || len(m.Buttons) > MaxMenuButtons {
+
+		return fmt.Errorf("Menu: expect 1~%d buttons but got %d", MaxMenuButtons, len(m.Buttons))
+
+	}
+
+	for i := range m.Buttons {
+
+		b := &m.Buttons[i]
+
+		if err := b.validateName(MaxMenuNameBytes); err != nil {
+
+			return err
+
+		}
+
+		if len(b.SubButtons) == 0 {
+
+			if err := b.validateLeaf(); err != nil {
+
+				return err
+
+			}
+
+			continue
+
+		}
+
+		if len(b.SubButtons) > MaxMenuSubButtons {
+
+			return fmt.Errorf("Menu: button %q expect at most %d sub buttons but got %d", b.Name,
+				MaxMenuSubButtons, len(b.SubButtons))
+
+		}
+
+		for j := range b.SubButtons {
+
+			sub := &b.SubButtons[j]
+
+			if len(sub.SubButtons) != 0 {
+
+				return fmt.Errorf("Menu: sub button %q can't have sub buttons", sub.Name)
+
+			}
+
+			if err := sub.validateName(MaxMenuSubNameBytes); err != nil {
+
+				return err
+
+			}
+
+			if err := sub.validateLeaf(); err != nil {
+
+				return err
+
+			}
+
+		}
+
+	}
+
+	if m.MatchRule != nil && *m.MatchRule == (MatchRule{}) {
+
+		return fmt.Errorf("Menu: empty matchrule")
+
+	}
+
+	return nil
+
+}
+
+func (b *Button) validateName(max int) error {
+
+	if b.Name == "" || len(b.Name) > max {
+
+		return fmt.Errorf("Menu: button name %q should be 1~%d bytes", b.Name, max)
+
+	}
+
+	return nil
+
+}
+
+func (b *Button) validateLeaf() error {
+
+	var ok bool
+
+	switch b.Type {
+
+	case BUTTON_TYPE_CLICK, BUTTON_TYPE_SCANCODE_PUSH, BUTTON_TYPE_SCANCODE_WAITMSG,
+		BUTTON_TYPE_PIC_SYSPHOTO, BUTTON_TYPE_PIC_PHOTO_OR_ALBUM, BUTTON_TYPE_PIC_WEIXIN,
+		BUTTON_TYPE_LOCATION_SELECT:
+		ok = b.Key != "" && len(b.Key) <= MaxMenuKeyBytes
+	case BUTTON_TYPE_VIEW:
+		ok = b.URL != "" && len(b.URL) <= MaxMenuURLBytes
+	case BUTTON_TYPE_MINIPROGRAM:
+		ok = b.URL != "" && len(b.URL) <= MaxMenuURLBytes && b.AppID != "" && b.PagePath != ""
+	case BUTTON_TYPE_MEDIA_ID, BUTTON_TYPE_VIEW_LIMITED:
+		ok = b.MediaID != ""
+	case BUTTON_TYPE_ARTICLE_ID, BUTTON_TYPE_ARTICLE_VIEW_LIMIT:
+		ok = b.ArticleID != ""
+	default:
+		return fmt.Errorf("Menu: button %q has unknown type %+q", b.Name, b.Type)
+
+	}
+
+	if !ok {
+
+		return fmt.Errorf("Menu: button %q (%s) has bad or missing fields", b.Name, b.Type)
+
+	}
+
+	return nil
+
+}
+
+// Create the default menu, it replaces the current one.
+func (mp *MP) CreateMenu(ctx context.Context, menu *Menu, l wx.Logger) error {
+
+	if err := menu.Validate(); err != nil {
+
+		return err
+
+	}
+
+	if menu.MatchRule != nil {
+
+		return fmt.Errorf("CreateMenu: use AddConditionalMenu for menus with matchrule")
+
+	}
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/menu/create",
+		&Menu{Buttons: menu.Buttons}, nil, l)
+
+}
+
+type MenuResult struct {
+	// The default menu.
+	Menu Menu `json:"menu"`
+	// Conditional menus.
+	ConditionalMenus []Menu `json:"conditionalmenu"`
+}
+
+// Get the default menu and conditional menus. Return *wx.APIError of 46003 if no menu.
+func (mp *MP) GetMenu(ctx context.Context, l wx.Logger) (*MenuResult, error) {
+
+	r := &MenuResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/menu/get", nil, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r, nil
+
+}
+
+// Delete the default menu and all conditional menus.
+func (mp *MP) DeleteMenu(ctx context.Context, l wx.Logger) error {
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/menu/delete", nil, nil, l)
+
+}
+
+type addConditionalMenuResult struct {
+	wx.ResultBase
+
+	MenuID json.Number `json:"menuid"`
+}
+
+// Add a conditional menu (MatchRule required), return its menuid.
+func (mp *MP) AddConditionalMenu(ctx context.Context, menu *Menu, l wx.Logger) (int64, error) {
+
+	if err := menu.Validate(); err != nil {
+
+		return 0, err
+
+	}
+
+	if menu.MatchRule == nil {
+
+		return 0, fmt.Errorf("AddConditionalMenu: matchrule is required")
+
+	}
+
+	r := &addConditionalMenuResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/menu/addconditional",
+		&Menu{Buttons: menu.Buttons, MatchRule: menu.MatchRule}, r, l); err != nil {
+
+		return 0, err
+
+	}
+
+	// menuid is returned as string.
+	return r.MenuID.Int64()
+
+}
+
+// Delete a conditional menu.
+func (mp *MP) DelConditionalMenu(ctx context.Context, menuid int64, l wx.Logger) error {
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/menu/delconditional",
+		map[string]string{
+			"menuid": fmt.Sprintf("%d", menuid),
+		}, nil, l)
+
+}
+
+type tryMatchMenuResult struct {
+	wx.ResultBase
+
+	Buttons []Button `json:"button"`
+}
+
+// Return the menu buttons that the user (openid or wechat id) would see.
+func (mp *MP) TryMatchMenu(ctx context.Context, user_id string, l wx.Logger) ([]Button, error) {
+
+	r := &tryMatchMenuResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/menu/trymatch",
+		map[string]string{
+			"user_id": user_id,
+		}, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r.Buttons, nil
+
+}
@@ -0,0 +1,172 @@
+package mp
+
+import (
+	"fmt"
+)
+
+type MenuChangeKind string
+
+const (
+	MENU_CHANGE_ADD    MenuChangeKind = "add"
+	MENU_CHANGE_REMOVE MenuChangeKind = "remove"
+	MENU_CHANGE_MODIFY MenuChangeKind = "modify"
+)
+
+// A difference between two menus. Buttons are compared by position since order
+// matters in menus.
+type MenuChange struct {
+	Kind MenuChangeKind
+	// e.g. "button[1]", "button[1].sub_button[0]", "matchrule"
+	Path string
+	// Nil for MENU_CHANGE_ADD or path "matchrule".
+	Old *Button
+	// Nil for MENU_CHANGE_REMOVE or path "matchrule".
+	New *Button
+}
+
+func (c MenuChange) String() string {
+
+	name := func(b *Button) string {
+
+		if b == nil {
+
+			return ""
+
+		}
+
+		return b.Name
+
+	}
+
+	switch c.Kind {
+
+	case MENU_CHANGE_ADD:
+		return fmt.Sprintf("+ %s %q", c.Path, name(c.New))
+	case MENU_CHANGE_REMOVE:
+		return fmt.Sprintf("- %s %q", c.Path, name(c.Old))
+	default:
+		return fmt.Sprintf("~ %s %q -> %q", c.Path, name(c.Old), name(c.New))
+
+	}
+
+}
+
+// Diff the current menu against the desired one, return changes needed to turn
+// current into desired, or nil if they are the same. current can be nil (no menu).
+// MenuID is ignored.
+func DiffMenu(current, desired *Menu) []MenuChange {
+
+	if current == nil {
+
+		current = &Menu{}
+
+	}
+
+	if desired == nil {
+
+		desired = &Menu{}
+
+	}
+
+	changes := diffButtons("button", current.Buttons, desired.Buttons)
+
+	var old_rule, new_rule MatchRule
+
+	if current.MatchRule != nil {
+
+		old_rule = *current.MatchRule
+
+	}
+
+	if desired.MatchRule != nil {
+
+		new_rule = *desired.MatchRule
+
+	}
+
+	if old_rule != new_rule {
+
+		changes = append(changes, MenuChange{
+			Kind: MENU_CHANGE_MODIFY,
+			Path: "matchrule",
+		})
+
+	}
+
+	return changes
+
+}
+
+func diffButtons(prefix string, current, desired []Button) []MenuChange {
+
+	var changes []MenuChange
+
+	n := len(current)
+
+	if len(desired) > n {
+
+		n = len(desired)
+
+	}
+
+	for i := 0; i < n; i++ {
+
+		path := fmt.Sprintf("%s[%d]", prefix, i)
+
+		switch {
+
+		case i >= len(current):
+			changes = append(changes, MenuChange{
+				Kind: MENU_CHANGE_ADD,
+				Path: path,
+				New:  &desired[i],
+			})
+
+		case i >= len(desired):
+			changes = append(changes, MenuChange{
+				Kind: MENU_CHANGE_REMOVE,
+				Path: path,
+				Old:  &current[i],
+			})
+
+		default:
+			if !sameButton(&current[i], &desired[i]) {
+
+				changes = append(changes, MenuChange{
+					Kind: MENU_CHANGE_MODIFY,
+					Path: path,
+					Old:  &current[i],
+					New:  &desired[i],
+				})
+
+			}
+
+			changes = append(changes, diffButtons(path+".sub_button", current[i].SubButtons,
+				desired[i].SubButtons)...)
+
+		}
+
+	}
+
+	return changes
+
+}
+
+// Compare buttons without sub buttons. Type of buttons with sub buttons is ignored.
+func sameButton(a, b *Button) bool {
+
+	x, y := *a, *b
+
+	x.SubButtons, y.SubButtons = nil, nil
+
+	if len(a.SubButtons) != 0 && len(b.SubButtons) != 0 {
+
+		x.Type, y.Type = "", ""
+
+	}
+
+	return x.Type == y.Type && x.Name == y.Name && x.Key == y.Key && x.URL == y.URL &&
+		x.MediaID == y.MediaID && x.AppID == y.AppID && x.PagePath == y.PagePath &&
+		x.ArticleID == y.ArticleID
+
+}
@@ -0,0 +1,148 @@
+package mp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func click(name string) Button {
+
+	return Button{Type: BUTTON_TYPE_CLICK, Name: name, Key: "K"}
+
+}
+
+func parent(name string, n int) Button {
+
+	b := Button{Name: name}
+
+	for i := 0; i < n; i++ {
+
+		b.SubButtons = append(b.SubButtons, click("sub"))
+
+	}
+
+	return b
+
+}
+
+func TestMenuValidate(t *testing.T) {
+
+	for _, c := range []struct {
+		Name    string
+		Buttons []Button
+		OK      bool
+	}{
+		{"no_button", nil, false},
+		{"3_buttons", []Button{click("a"), click("b"), click("c")}, true},
+		{"4_buttons", []Button{click("a"), click("b"), click("c"), click("d")}, false},
+		{"5_sub_buttons", []Button{parent("a", 5)}, true},
+		{"6_sub_buttons", []Button{parent("a", 6)}, false},
+		{"nested_sub_buttons", []Button{{Name: "a", SubButtons: []Button{parent("b", 1)}}}, false},
+		{"16_bytes_name", []Button{click(strings.Repeat("a", 16))}, true},
+		{"17_bytes_name", []Button{click(strings.Repeat("a", 17))}, false},
+		{"15_bytes_chinese_name", []Button{click("今日歌曲推")}, true},
+		{"18_bytes_chinese_name", []Button{click("今日歌曲推荐")}, false},
+		{"empty_name", []Button{click("")}, false},
+		{"60_bytes_sub_name", []Button{{Name: "a", SubButtons: []Button{click(strings.Repeat("a", 60))}}}, true},
+		{"61_bytes_sub_name", []Button{{Name: "a", SubButtons: []Button{click(strings.Repeat("a", 61))}}}, false},
+		{"missing_key", []Button{{Type: BUTTON_TYPE_CLICK, Name: "a"}}, false},
+		{"missing_url", []Button{{Type: BUTTON_TYPE_VIEW, Name: "a"}}, false},
+		{"unknown_type", []Button{{Type: "xxx", Name: "a", Key: "K"}}, false},
+	} {
+
+		err := (&Menu{Buttons: c.Buttons}).Validate()
+
+		if (err == nil) != c.OK {
+
+			t.Errorf("%s: expect ok=%v, got %v", c.Name, c.OK, err)
+
+		}
+
+	}
+
+	if err := (&Menu{Buttons: []Button{click("a")}, MatchRule: &MatchRule{}}).Validate(); err == nil {
+
+		t.Error("expect error for empty matchrule")
+
+	}
+
+}
+
+func TestDiffMenu(t *testing.T) {
+
+	current := &Menu{
+		Buttons: []Button{
+			click("a"),
+			{Name: "b", SubButtons: []Button{click("b0"), click("b1")}},
+		},
+		MenuID: 1,
+	}
+
+	for _, c := range []struct {
+		Name    string
+		Current *Menu
+		Desired *Menu
+		Expect  []string
+	}{
+		{
+			"same",
+			current,
+			&Menu{Buttons: current.Buttons},
+			nil,
+		},
+		{
+			"from_nil",
+			nil,
+			&Menu{Buttons: []Button{click("a")}},
+			[]string{`+ button[0] "a"`},
+		},
+		{
+			"add",
+			current,
+			&Menu{Buttons: append(append([]Button(nil), current.Buttons...), click("c"))},
+			[]string{`+ button[2] "c"`},
+		},
+		{
+			"remove",
+			current,
+			&Menu{Buttons: current.Buttons[:1]},
+			[]string{`- button[1] "b"`},
+		},
+		{
+			"modify",
+			current,
+			&Menu{Buttons: []Button{{Type: BUTTON_TYPE_CLICK, Name: "a", Key: "K2"}, current.Buttons[1]}},
+			[]string{`~ button[0] "a" -> "a"`},
+		},
+		{
+			"sub_buttons",
+			current,
+			&Menu{Buttons: []Button{click("a"), {Name: "b", SubButtons: []Button{click("b0x")}}}},
+			[]string{`~ button[1].sub_button[0] "b0" -> "b0x"`, `- button[1].sub_button[1] "b1"`},
+		},
+		{
+			"matchrule",
+			current,
+			&Menu{Buttons: current.Buttons, MatchRule: &MatchRule{TagID: "2"}},
+			[]string{`~ matchrule "" -> ""`},
+		},
+	} {
+
+		var got []string
+
+		for _, change := range DiffMenu(c.Current, c.Desired) {
+
+			got = append(got, change.String())
+
+		}
+
+		if !reflect.DeepEqual(got, c.Expect) {
+
+			t.Errorf("%s: expect %q, got %q", c.Name, c.Expect, got)
+
+		}
+
+	}
+
+}
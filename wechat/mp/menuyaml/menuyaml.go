@@ -0,0 +1,44 @@
+// Package menuyaml loads official account menu definitions in YAML. It is kept
+// apart from package mp so that mp does not depend on a yaml library.
+package menuyaml
+
+import (
+	"fmt"
+	"github.com/huangjunwen/WechatDriver/wechat/mp"
+	"gopkg.in/yaml.v2"
+	"io"
+)
+
+// Load a menu definition in YAML, field names are the same as JSON (see the yaml
+// tags of mp.Menu/mp.Button):
+//
+//	button:
+//	  - type: click
+//	    name: Today
+//	    key: V1001_TODAY_MUSIC
+//	  - name: Menu
+//	    sub_button:
+//	      - type: view
+//	        name: Search
+//	        url: http://www.soso.com/
+//	matchrule:
+//	  tag_id: "2"
+//
+// Unknown or duplicated fields are errors.
+func Load(r io.Reader) (*mp.Menu, error) {
+
+	menu := &mp.Menu{}
+
+	dec := yaml.NewDecoder(r)
+
+	dec.SetStrict(true)
+
+	if err := dec.Decode(menu); err != nil {
+
+		return nil, fmt.Errorf("menuyaml.Load: %s", err.Error())
+
+	}
+
+	return menu, nil
+
+}
@@ -0,0 +1,89 @@
+package menuyaml
+
+import (
+	"github.com/huangjunwen/WechatDriver/wechat/mp"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+
+	src := `---
+# Menu definition
+button:
+  - type: click
+    name: "Today # 1"
+    key: V1001_TODAY_MUSIC   # comment
+  - name: 'It''s menu'
+    sub_button:
+    - type: view
+      name: Search
+      url: http://www.soso.com/?a=1
+    -
+      type: miniprogram
+      name: wxa
+      url: "http://mp.weixin.qq.com"
+      appid: wx286b93c14bbf93aa
+      pagepath: pages/lunar/index
+matchrule:
+  tag_id: 2
+  client_platform_type: "1"
+`
+
+	menu, err := Load(strings.NewReader(src))
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	expect := &mp.Menu{
+		Buttons: []mp.Button{
+			{Type: mp.BUTTON_TYPE_CLICK, Name: "Today # 1", Key: "V1001_TODAY_MUSIC"},
+			{Name: "It's menu", SubButtons: []mp.Button{
+				{Type: mp.BUTTON_TYPE_VIEW, Name: "Search", URL: "http://www.soso.com/?a=1"},
+				{Type: mp.BUTTON_TYPE_MINIPROGRAM, Name: "wxa", URL: "http://mp.weixin.qq.com",
+					AppID: "wx286b93c14bbf93aa", PagePath: "pages/lunar/index"},
+			}},
+		},
+		MatchRule: &mp.MatchRule{TagID: "2", ClientPlatformType: "1"},
+	}
+
+	if !reflect.DeepEqual(menu, expect) {
+
+		t.Fatalf("expect %+v, got %+v", expect, menu)
+
+	}
+
+	if err := menu.Validate(); err != nil {
+
+		t.Fatal(err)
+
+	}
+
+}
+
+func TestLoadError(t *testing.T) {
+
+	for _, src := range []string{
+		"button:\n  - name: a\n     key: b\n",
+		"button:\n  - name: a\n    nmae: b\n",
+		"button:\n  - name: a\n    name: b\n",
+		"button: [a, b]\n",
+		"button:\n\t- name: a\n",
+		"button:\n  - name: \"a\n",
+		"button: a\n",
+		"menuid: 1\n",
+	} {
+
+		if _, err := Load(strings.NewReader(src)); err == nil {
+
+			t.Errorf("expect error for %q", src)
+
+		}
+
+	}
+
+}
@@ -0,0 +1,38 @@
+// Package mp provides APIs of official accounts (公众号), such as template
+// messages, customer service messages, menus, QR codes, media and users. The
+// callback side (inbound messages) is in package mp/server.
+package mp
+
+import (
+	"fmt"
+	"github.com/huangjunwen/WechatDriver/wechat/token"
+)
+
+// Communicate to Wechat's official account APIs.
+type MP struct {
+	// The app access token manager.
+	tokens *token.AccessTokenManager
+
+	// If not nil, template messages sent are tracked by it.
+	TemplateTracker *TemplateTracker
+}
+
+// Create MP instance using the app access token manager.
+func NewMP(tokens *token.AccessTokenManager) (*MP, error) {
+
+	if tokens == nil {
+
+		return nil, fmt.Errorf("NewMP: tokens missing")
+
+	}
+
+	return &MP{
+		tokens: tokens,
+	}, nil
+
+}
+
+// Return the app access token manager associated.
+func (mp *MP) Tokens() *token.AccessTokenManager {
+	return mp.tokens
+}
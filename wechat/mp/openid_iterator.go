@@ -0,0 +1,133 @@
+package mp
+
+import (
+	"context"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+)
+
+// Max number of openids in a page of user/get alike APIs.
+const OpenIDPageSize = 10000
+
+// A page of openids.
+type openIDPage struct {
+	wx.ResultBase
+
+	Total int `json:"total"`
+	Count int `json:"count"`
+	Data  struct {
+		OpenID []string `json:"openid"`
+	} `json:"data"`
+	NextOpenID string `json:"next_openid"`
+}
+
+// OpenIDIterator iterates openids page by page (at most 10000 per page) using the
+// next_openid cursor:
+//
+//	it := mp.Followers(ctx, "", l)
+//	for it.Next() {
+//		openid := it.OpenID()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type OpenIDIterator struct {
+	ctx   context.Context
+	l     wx.Logger
+	fetch func(ctx context.Context, next_openid string, l wx.Logger) (*openIDPage, error)
+
+	next  string
+	page  []string
+	i     int
+	total int
+	done  bool
+	err   error
+}
+
+func newOpenIDIterator(ctx context.Context, next_openid string, l wx.Logger,
+	fetch func(ctx context.Context, next_openid string, l wx.Logger) (*openIDPage, error)) *OpenIDIterator {
+
+	return &OpenIDIterator{
+		ctx:   ctx,
+		l:     l,
+		fetch: fetch,
+		next:  next_openid,
+		i:     -1,
+	}
+
+}
+
+// Advance to the next openid, return false when done or on error (see Err).
+func (it *OpenIDIterator) Next() bool {
+
+	if it.err != nil {
+
+		return false
+
+	}
+
+	it.i++
+
+	for it.i >= len(it.page) {
+
+		if it.done {
+
+			return false
+
+		}
+
+		page, err := it.fetch(it.ctx, it.next, it.l)
+
+		if err != nil {
+
+			it.err = err
+
+			return false
+
+		}
+
+		it.page = page.Data.OpenID
+
+		it.i = 0
+
+		it.total = page.Total
+
+		// The last page is not full, and an empty page follows a full last page.
+		if page.Count < OpenIDPageSize || len(page.Data.OpenID) == 0 || page.NextOpenID == "" {
+
+			it.done = true
+
+		}
+
+		if page.NextOpenID != "" {
+
+			it.next = page.NextOpenID
+
+		}
+
+	}
+
+	return true
+
+}
+
+// Return the current openid.
+func (it *OpenIDIterator) OpenID() string {
+	return it.page[it.i]
+}
+
+// Return the error stopped the iteration.
+func (it *OpenIDIterator) Err() error {
+	return it.err
+}
+
+// Return total number reported by Wechat (after the first Next). NOTE: not all
+// APIs report it.
+func (it *OpenIDIterator) Total() int {
+	return it.total
+}
+
+// Return the cursor of the next page, it can be saved to resume the iteration later.
+func (it *OpenIDIterator) NextOpenID() string {
+	return it.next
+}
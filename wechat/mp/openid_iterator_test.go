@@ -0,0 +1,267 @@
+package mp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Serve cgi-bin/user/get with n followers "o0", "o1"... fail_after is the number
+// of pages served before responding errors (-1 to never fail).
+func followersHandler(t *testing.T, n int, fail_after int, cursors *[]string) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Path != "/cgi-bin/user/get" {
+
+			t.Errorf("unexpected path %+q", r.URL.Path)
+
+			return
+
+		}
+
+		next_openid := r.URL.Query().Get("next_openid")
+
+		*cursors = append(*cursors, next_openid)
+
+		if fail_after >= 0 && len(*cursors) > fail_after {
+
+			fmt.Fprint(w, `{"errcode":45009,"errmsg":"reach max api daily quota limit"}`)
+
+			return
+
+		}
+
+		start := 0
+
+		if next_openid != "" {
+
+			start, _ = strconv.Atoi(strings.TrimPrefix(next_openid, "o")) // Index of the last one.
+
+			start++
+
+		}
+
+		end := start + OpenIDPageSize
+
+		if end > n {
+
+			end = n
+
+		}
+
+		if start >= end {
+
+			// Wechat omits data of an empty page.
+			fmt.Fprintf(w, `{"total":%d,"count":0,"next_openid":""}`, n)
+
+			return
+
+		}
+
+		page := &openIDPage{Total: n, Count: end - start, NextOpenID: fmt.Sprintf("o%d", end-1)}
+
+		for i := start; i < end; i++ {
+
+			page.Data.OpenID = append(page.Data.OpenID, fmt.Sprintf("o%d", i))
+
+		}
+
+		json.NewEncoder(w).Encode(page)
+
+	}
+
+}
+
+func TestOpenIDIterator(t *testing.T) {
+
+	for _, c := range []struct {
+		Name    string
+		N       int
+		Cursors []string
+	}{
+		{"empty", 0, []string{""}},
+		{"one_page", 3, []string{""}},
+		{"multi_pages", 2*OpenIDPageSize + 3, []string{"", "o9999", "o19999"}},
+		// The last page is full, an empty page follows.
+		{"full_last_page", 2 * OpenIDPageSize, []string{"", "o9999", "o19999"}},
+	} {
+
+		var cursors []string
+
+		mp := newTestMP(t, followersHandler(t, c.N, -1, &cursors))
+
+		it := mp.Followers(context.Background(), "", nil)
+
+		n := 0
+
+		for it.Next() {
+
+			if openid := it.OpenID(); openid != fmt.Sprintf("o%d", n) {
+
+				t.Fatalf("%s: expect o%d, got %+q", c.Name, n, openid)
+
+			}
+
+			n++
+
+		}
+
+		if err := it.Err(); err != nil {
+
+			t.Fatalf("%s: %s", c.Name, err)
+
+		}
+
+		if n != c.N || it.Total() != c.N {
+
+			t.Errorf("%s: expect %d openids, got %d (total %d)", c.Name, c.N, n, it.Total())
+
+		}
+
+		if strings.Join(cursors, ",") != strings.Join(c.Cursors, ",") {
+
+			t.Errorf("%s: expect cursors %q, got %q", c.Name, c.Cursors, cursors)
+
+		}
+
+		// Done, no more fetching.
+		if it.Next() || len(cursors) != len(c.Cursors) {
+
+			t.Errorf("%s: expect no more fetching", c.Name)
+
+		}
+
+	}
+
+}
+
+// An error in the middle stops the iteration, which can be resumed from NextOpenID.
+func TestOpenIDIteratorError(t *testing.T) {
+
+	var cursors []string
+
+	mp := newTestMP(t, followersHandler(t, 2*OpenIDPageSize+3, 1, &cursors))
+
+	it := mp.Followers(context.Background(), "", nil)
+
+	n := 0
+
+	for it.Next() {
+
+		n++
+
+	}
+
+	if it.Err() == nil || n != OpenIDPageSize {
+
+		t.Fatalf("expect error after %d openids, got %d %v", OpenIDPageSize, n, it.Err())
+
+	}
+
+	// Stays stopped.
+	if it.Next() || len(cursors) != 2 {
+
+		t.Fatal("expect no more fetching after error")
+
+	}
+
+	// Resume.
+	cursors = nil
+
+	mp = newTestMP(t, followersHandler(t, 2*OpenIDPageSize+3, -1, &cursors))
+
+	it = mp.Followers(context.Background(), it.NextOpenID(), nil)
+
+	for it.Next() {
+
+		if openid := it.OpenID(); openid != fmt.Sprintf("o%d", n) {
+
+			t.Fatalf("expect o%d, got %+q", n, openid)
+
+		}
+
+		n++
+
+	}
+
+	if it.Err() != nil || n != 2*OpenIDPageSize+3 {
+
+		t.Fatalf("expect all openids after resuming, got %d %v", n, it.Err())
+
+	}
+
+}
+
+func TestBatchTagBlacklistArgs(t *testing.T) {
+
+	var calls []string
+
+	mp := newTestMP(t, func(w http.ResponseWriter, r *http.Request) {
+
+		calls = append(calls, r.URL.Path)
+
+		fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+
+	})
+
+	openids := func(n int) []string {
+
+		ret := make([]string, n)
+
+		for i := range ret {
+
+			ret[i] = fmt.Sprintf("o%d", i)
+
+		}
+
+		return ret
+
+	}
+
+	ctx := context.Background()
+
+	for _, c := range []struct {
+		Name string
+		Call func() error
+		OK   bool
+	}{
+		{"tagging_0", func() error { return mp.BatchTagging(ctx, 100, openids(0), nil) }, false},
+		{"tagging_50", func() error { return mp.BatchTagging(ctx, 100, openids(MaxBatchTagging), nil) }, true},
+		{"tagging_51", func() error { return mp.BatchTagging(ctx, 100, openids(MaxBatchTagging+1), nil) }, false},
+		{"untagging_51", func() error { return mp.BatchUntagging(ctx, 100, openids(MaxBatchTagging+1), nil) }, false},
+		{"blacklist_0", func() error { return mp.BatchBlacklist(ctx, openids(0), nil) }, false},
+		{"blacklist_20", func() error { return mp.BatchBlacklist(ctx, openids(MaxBatchBlacklist), nil) }, true},
+		{"blacklist_21", func() error { return mp.BatchBlacklist(ctx, openids(MaxBatchBlacklist+1), nil) }, false},
+		{"unblacklist_21", func() error { return mp.BatchUnblacklist(ctx, openids(MaxBatchBlacklist+1), nil) }, false},
+	} {
+
+		calls = nil
+
+		err := c.Call()
+
+		if (err == nil) != c.OK {
+
+			t.Errorf("%s: expect ok=%v, got %v", c.Name, c.OK, err)
+
+		}
+
+		if expect := map[bool]int{true: 1, false: 0}[c.OK]; len(calls) != expect {
+
+			t.Errorf("%s: expect %d calls, got %v", c.Name, expect, calls)
+
+		}
+
+	}
+
+	if MaxBatchTagging != 50 || MaxBatchBlacklist != 20 {
+
+		t.Fatalf("unexpected limits %d %d", MaxBatchTagging, MaxBatchBlacklist)
+
+	}
+
+}
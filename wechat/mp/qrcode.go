@@ -0,0 +1,200 @@
+package mp
+
+import (
+	"context"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/mp/server"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Max expire time of temporary QR codes.
+const MaxQRCodeExpire = 30 * 24 * time.Hour
+
+type QRActionName string
+
+const (
+	QR_SCENE           QRActionName = "QR_SCENE"
+	QR_STR_SCENE       QRActionName = "QR_STR_SCENE"
+	QR_LIMIT_SCENE     QRActionName = "QR_LIMIT_SCENE"
+	QR_LIMIT_STR_SCENE QRActionName = "QR_LIMIT_STR_SCENE"
+)
+
+// Is it a permanent QR code ?
+func (a QRActionName) Permanent() bool {
+	return a == QR_LIMIT_SCENE || a == QR_LIMIT_STR_SCENE
+}
+
+// Does it take a string scene ?
+func (a QRActionName) StrScene() bool {
+	return a == QR_STR_SCENE || a == QR_LIMIT_STR_SCENE
+}
+
+type qrCodeScene struct {
+	SceneID  uint32 `json:"scene_id,omitempty"`
+	SceneStr string `json:"scene_str,omitempty"`
+}
+
+type qrCodeActionInfo struct {
+	Scene qrCodeScene `json:"scene"`
+}
+
+type qrCodeRequest struct {
+	ExpireSeconds int64            `json:"expire_seconds,omitempty"`
+	ActionName    QRActionName     `json:"action_name"`
+	ActionInfo    qrCodeActionInfo `json:"action_info"`
+}
+
+type QRCodeResult struct {
+	// Used to download the QR code image.
+	Ticket string `json:"ticket"`
+	// 0 for permanent QR codes.
+	ExpireSeconds int64 `json:"expire_seconds"`
+	// The content of the QR code image.
+	URL string `json:"url"`
+}
+
+// Create a parametric QR code, the scene is reported in subscribe/SCAN events (see
+// server.SubscribeEvent.QRScene). expire is required for temporary QR codes: at
+// least 1 second and at most MaxQRCodeExpire; it is ignored for permanent ones.
+func (mp *MP) CreateQRCode(ctx context.Context, action QRActionName, scene server.QRScene,
+	expire time.Duration, l wx.Logger) (*QRCodeResult, error) {
+
+	switch action {
+
+	case QR_SCENE, QR_STR_SCENE, QR_LIMIT_SCENE, QR_LIMIT_STR_SCENE:
+	default:
+		return nil, fmt.Errorf("CreateQRCode: unknown action_name %+q", action)
+
+	}
+
+	if action.StrScene() == scene.IsID() {
+
+		return nil, fmt.Errorf("CreateQRCode: %s does not match scene %+q", action, scene.String())
+
+	}
+
+	if err := scene.Validate(action.Permanent()); err != nil {
+
+		return nil, err
+
+	}
+
+	req := &qrCodeRequest{
+		ActionName: action,
+		ActionInfo: qrCodeActionInfo{
+			Scene: qrCodeScene{
+				SceneID:  scene.ID,
+				SceneStr: scene.Str,
+			},
+		},
+	}
+
+	if !action.Permanent() {
+
+		expire_seconds := int64(expire / time.Second)
+
+		if expire_seconds <= 0 || expire_seconds > int64(MaxQRCodeExpire/time.Second) {
+
+			return nil, fmt.Errorf("CreateQRCode: expire should be 1s~%s but got %s", MaxQRCodeExpire, expire)
+
+		}
+
+		req.ExpireSeconds = expire_seconds
+
+	}
+
+	r := &QRCodeResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/qrcode/create", req, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r, nil
+
+}
+
+// Return the image URL of a QR code ticket.
+func QRCodeImageURL(ticket string) string {
+
+	return "https://mp.weixin.qq.com/cgi-bin/showqrcode?" + url.Values{
+		"ticket": []string{ticket},
+	}.Encode()
+
+}
+
+// Download the QR code image of ticket and stream it to w, return the image's
+// content type.
+func (mp *MP) DownloadQRCode(ctx context.Context, ticket string, w io.Writer, l wx.Logger) (string, error) {
+
+	URL := QRCodeImageURL(ticket)
+
+	if l != nil {
+
+		l.Printf("method=\"GET\" url=%+q\n", URL)
+
+	}
+
+	req, err := http.NewRequest("GET", URL, nil)
+
+	if err != nil {
+
+		return "", err
+
+	}
+
+	resp, err := mp.tokens.Client().Do(req.WithContext(ctx))
+
+	if err != nil {
+
+		return "", err
+
+	}
+
+	defer wx.CloseResponse(resp)
+
+	content_type := resp.Header.Get("Content-Type")
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+
+		// Returns *wx.HTTPError.
+		return "", wx.ReadResponse(resp, ioutil.Discard, 0)
+
+	}
+
+	if media_type, _, _ := mime.ParseMediaType(content_type); !strings.HasPrefix(media_type, "image/") {
+
+		snippet, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return "", &wx.ContentTypeError{
+			ContentType: content_type,
+			Body:        snippet,
+		}
+
+	}
+
+	n, err := io.Copy(w, resp.Body)
+
+	if l != nil {
+
+		l.Printf("status=%+q proto=%+q content_type=%+q body_size=%d\n", resp.Status, resp.Proto, content_type, n)
+
+	}
+
+	if err != nil {
+
+		return "", err
+
+	}
+
+	return content_type, nil
+
+}
@@ -0,0 +1,49 @@
+package mp
+
+import (
+	"context"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/mp/server"
+	"github.com/huangjunwen/WechatDriver/wechat/token"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Invalid expire of temporary QR codes is rejected before calling Wechat.
+func TestCreateQRCodeExpire(t *testing.T) {
+
+	tokens, err := token.NewAccessTokenManager(&wx.AppConfig{AppID: "app", AppSecret: "secret"}, nil)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	mp, err := NewMP(tokens)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	for _, expire := range []time.Duration{
+		-time.Second,
+		0,
+		500 * time.Millisecond,
+		MaxQRCodeExpire + time.Second,
+	} {
+
+		_, err := mp.CreateQRCode(context.Background(), QR_SCENE, server.QRScene{ID: 1}, expire, nil)
+
+		if err == nil || !strings.HasPrefix(err.Error(), "CreateQRCode: expire") {
+
+			t.Errorf("%s: expect expire error, got %v", expire, err)
+
+		}
+
+	}
+
+}
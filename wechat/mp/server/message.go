@@ -0,0 +1,252 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+type MsgType string
+
+const (
+	MSG_TYPE_TEXT       MsgType = "text"
+	MSG_TYPE_IMAGE      MsgType = "image"
+	MSG_TYPE_VOICE      MsgType = "voice"
+	MSG_TYPE_VIDEO      MsgType = "video"
+	MSG_TYPE_SHORTVIDEO MsgType = "shortvideo"
+	MSG_TYPE_LOCATION   MsgType = "location"
+	MSG_TYPE_LINK       MsgType = "link"
+	MSG_TYPE_EVENT      MsgType = "event"
+)
+
+type EventType string
+
+const (
+	EVENT_SUBSCRIBE             EventType = "subscribe"
+	EVENT_UNSUBSCRIBE           EventType = "unsubscribe"
+	EVENT_SCAN                  EventType = "SCAN"
+	EVENT_LOCATION              EventType = "LOCATION"
+	EVENT_CLICK                 EventType = "CLICK"
+	EVENT_VIEW                  EventType = "VIEW"
+	EVENT_TEMPLATESENDJOBFINISH EventType = "TEMPLATESENDJOBFINISH"
+	EVENT_SUBSCRIBE_MSG_POPUP   EventType = "subscribe_msg_popup_event"
+	EVENT_SUBSCRIBE_MSG_CHANGE  EventType = "subscribe_msg_change_event"
+)
+
+// An inbound message or event.
+type Message interface {
+	// Return the common part of the message.
+	Header() *MessageHeader
+}
+
+// Common part of all inbound messages and events.
+type MessageHeader struct {
+	ToUserName   string    `xml:"ToUserName"`   // The official account's id
+	FromUserName string    `xml:"FromUserName"` // The user's openid
+	CreateTime   int64     `xml:"CreateTime"`
+	MsgType      MsgType   `xml:"MsgType"`
+	Event        EventType `xml:"Event"` // Only for MSG_TYPE_EVENT
+}
+
+func (h *MessageHeader) Header() *MessageHeader {
+	return h
+}
+
+// --- Messages
+
+type TextMessage struct {
+	MessageHeader
+	Content string `xml:"Content"`
+	MsgID   int64  `xml:"MsgId"`
+}
+
+type ImageMessage struct {
+	MessageHeader
+	PicURL  string `xml:"PicUrl"`
+	MediaID string `xml:"MediaId"`
+	MsgID   int64  `xml:"MsgId"`
+}
+
+type VoiceMessage struct {
+	MessageHeader
+	MediaID string `xml:"MediaId"`
+	Format  string `xml:"Format"`
+	// Speech recognition result, only when enabled.
+	Recognition string `xml:"Recognition"`
+	MsgID       int64  `xml:"MsgId"`
+}
+
+// For both MSG_TYPE_VIDEO and MSG_TYPE_SHORTVIDEO.
+type VideoMessage struct {
+	MessageHeader
+	MediaID      string `xml:"MediaId"`
+	ThumbMediaID string `xml:"ThumbMediaId"`
+	MsgID        int64  `xml:"MsgId"`
+}
+
+type LocationMessage struct {
+	MessageHeader
+	LocationX float64 `xml:"Location_X"` // Latitude
+	LocationY float64 `xml:"Location_Y"` // Longitude
+	Scale     int     `xml:"Scale"`
+	Label     string  `xml:"Label"`
+	MsgID     int64   `xml:"MsgId"`
+}
+
+type LinkMessage struct {
+	MessageHeader
+	Title       string `xml:"Title"`
+	Description string `xml:"Description"`
+	URL         string `xml:"Url"`
+	MsgID       int64  `xml:"MsgId"`
+}
+
+// --- Events
+
+// User subscribed. EventKey ("qrscene_" prefixed) and Ticket are present when
+// subscribing by scanning a parametric QR code.
+type SubscribeEvent struct {
+	MessageHeader
+	EventKey string `xml:"EventKey"`
+	Ticket   string `xml:"Ticket"`
+}
+
+type UnsubscribeEvent struct {
+	MessageHeader
+}
+
+// A subscribed user scanned a parametric QR code.
+type ScanEvent struct {
+	MessageHeader
+	EventKey string `xml:"EventKey"`
+	Ticket   string `xml:"Ticket"`
+}
+
+// User location reported.
+type LocationEvent struct {
+	MessageHeader
+	Latitude  float64 `xml:"Latitude"`
+	Longitude float64 `xml:"Longitude"`
+	Precision float64 `xml:"Precision"`
+}
+
+// Click menu.
+type ClickEvent struct {
+	MessageHeader
+	EventKey string `xml:"EventKey"`
+}
+
+// View (jump to URL) menu.
+type ViewEvent struct {
+	MessageHeader
+	EventKey string `xml:"EventKey"` // The URL
+	MenuID   string `xml:"MenuId"`
+}
+
+// Template message sending result.
+type TemplateSendJobFinishEvent struct {
+	MessageHeader
+	MsgID  int64  `xml:"MsgID"`
+	Status string `xml:"Status"` // "success", "failed:user block", "failed: system failed"
+}
+
+// User's choice on a subscribe message template.
+type SubscribeMsgStatus struct {
+	TemplateID string `xml:"TemplateId"`
+	// "accept" or "reject"
+	SubscribeStatusString string `xml:"SubscribeStatusString"`
+	// 1: in web page, 2: in official account's message. Only for popup event.
+	PopupScene int `xml:"PopupScene"`
+}
+
+// User accepted/rejected subscribe messages in a popup.
+type SubscribeMsgPopupEvent struct {
+	MessageHeader
+	List []SubscribeMsgStatus `xml:"SubscribeMsgPopupEvent>List"`
+}
+
+// User changed subscribe message settings (e.g. rejected later).
+type SubscribeMsgChangeEvent struct {
+	MessageHeader
+	List []SubscribeMsgStatus `xml:"SubscribeMsgChangeEvent>List"`
+}
+
+// Any message/event not listed above, use the raw XML (Context.RawXML) to
+// parse it.
+type UnknownMessage struct {
+	MessageHeader
+}
+
+// Parse an inbound message/event (plain XML).
+func ParseMessage(data []byte) (Message, error) {
+
+	header := &MessageHeader{}
+
+	if err := xml.Unmarshal(data, header); err != nil {
+
+		return nil, err
+
+	}
+
+	var msg Message
+
+	switch header.MsgType {
+
+	case MSG_TYPE_TEXT:
+		msg = &TextMessage{}
+	case MSG_TYPE_IMAGE:
+		msg = &ImageMessage{}
+	case MSG_TYPE_VOICE:
+		msg = &VoiceMessage{}
+	case MSG_TYPE_VIDEO, MSG_TYPE_SHORTVIDEO:
+		msg = &VideoMessage{}
+	case MSG_TYPE_LOCATION:
+		msg = &LocationMessage{}
+	case MSG_TYPE_LINK:
+		msg = &LinkMessage{}
+	case MSG_TYPE_EVENT:
+		msg = newEvent(header.Event)
+	case "":
+		return nil, fmt.Errorf("ParseMessage: MsgType missing")
+	default:
+		msg = &UnknownMessage{}
+
+	}
+
+	if err := xml.Unmarshal(data, msg); err != nil {
+
+		return nil, err
+
+	}
+
+	return msg, nil
+
+}
+
+func newEvent(event EventType) Message {
+
+	switch event {
+
+	case EVENT_SUBSCRIBE:
+		return &SubscribeEvent{}
+	case EVENT_UNSUBSCRIBE:
+		return &UnsubscribeEvent{}
+	case EVENT_SCAN:
+		return &ScanEvent{}
+	case EVENT_LOCATION:
+		return &LocationEvent{}
+	case EVENT_CLICK:
+		return &ClickEvent{}
+	case EVENT_VIEW:
+		return &ViewEvent{}
+	case EVENT_TEMPLATESENDJOBFINISH:
+		return &TemplateSendJobFinishEvent{}
+	case EVENT_SUBSCRIBE_MSG_POPUP:
+		return &SubscribeMsgPopupEvent{}
+	case EVENT_SUBSCRIBE_MSG_CHANGE:
+		return &SubscribeMsgChangeEvent{}
+	default:
+		return &UnknownMessage{}
+
+	}
+
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Wrap fields in a message XML from user "u" to "gh".
+func messageXML(msg_type, fields string) []byte {
+
+	return []byte(`<xml><ToUserName><![CDATA[gh]]></ToUserName><FromUserName><![CDATA[u]]></FromUserName>` +
+		`<CreateTime>1348831860</CreateTime><MsgType><![CDATA[` + msg_type + `]]></MsgType>` + fields + `</xml>`)
+
+}
+
+func header(msg_type MsgType, event EventType) MessageHeader {
+
+	return MessageHeader{
+		ToUserName:   "gh",
+		FromUserName: "u",
+		CreateTime:   1348831860,
+		MsgType:      msg_type,
+		Event:        event,
+	}
+
+}
+
+func TestParseMessage(t *testing.T) {
+
+	for _, c := range []struct {
+		Name   string
+		XML    []byte
+		Expect Message
+	}{
+		{
+			"text",
+			messageXML("text", `<Content><![CDATA[hello]]></Content><MsgId>1234567890123456</MsgId>`),
+			&TextMessage{header(MSG_TYPE_TEXT, ""), "hello", 1234567890123456},
+		},
+		{
+			"image",
+			messageXML("image", `<PicUrl><![CDATA[http://p]]></PicUrl><MediaId><![CDATA[m]]></MediaId><MsgId>1</MsgId>`),
+			&ImageMessage{header(MSG_TYPE_IMAGE, ""), "http://p", "m", 1},
+		},
+		{
+			"voice",
+			messageXML("voice", `<MediaId><![CDATA[m]]></MediaId><Format><![CDATA[amr]]></Format>`+
+				`<Recognition><![CDATA[你好]]></Recognition><MsgId>1</MsgId>`),
+			&VoiceMessage{header(MSG_TYPE_VOICE, ""), "m", "amr", "你好", 1},
+		},
+		{
+			"video",
+			messageXML("video", `<MediaId><![CDATA[m]]></MediaId><ThumbMediaId><![CDATA[t]]></ThumbMediaId><MsgId>1</MsgId>`),
+			&VideoMessage{header(MSG_TYPE_VIDEO, ""), "m", "t", 1},
+		},
+		{
+			"shortvideo",
+			messageXML("shortvideo", `<MediaId><![CDATA[m]]></MediaId><ThumbMediaId><![CDATA[t]]></ThumbMediaId><MsgId>1</MsgId>`),
+			&VideoMessage{header(MSG_TYPE_SHORTVIDEO, ""), "m", "t", 1},
+		},
+		{
+			"location",
+			messageXML("location", `<Location_X>23.134521</Location_X><Location_Y>113.358803</Location_Y>`+
+				`<Scale>20</Scale><Label><![CDATA[l]]></Label><MsgId>1</MsgId>`),
+			&LocationMessage{header(MSG_TYPE_LOCATION, ""), 23.134521, 113.358803, 20, "l", 1},
+		},
+		{
+			"link",
+			messageXML("link", `<Title><![CDATA[t]]></Title><Description><![CDATA[d]]></Description>`+
+				`<Url><![CDATA[http://u]]></Url><MsgId>1</MsgId>`),
+			&LinkMessage{header(MSG_TYPE_LINK, ""), "t", "d", "http://u", 1},
+		},
+		{
+			"unknown_message",
+			messageXML("miniprogrampage", `<Title><![CDATA[t]]></Title>`),
+			&UnknownMessage{header("miniprogrampage", "")},
+		},
+		{
+			"subscribe",
+			messageXML("event", `<Event><![CDATA[subscribe]]></Event>`),
+			&SubscribeEvent{MessageHeader: header(MSG_TYPE_EVENT, EVENT_SUBSCRIBE)},
+		},
+		{
+			"subscribe_qrscene",
+			messageXML("event", `<Event><![CDATA[subscribe]]></Event><EventKey><![CDATA[qrscene_123]]></EventKey>`+
+				`<Ticket><![CDATA[tk]]></Ticket>`),
+			&SubscribeEvent{header(MSG_TYPE_EVENT, EVENT_SUBSCRIBE), "qrscene_123", "tk"},
+		},
+		{
+			"unsubscribe",
+			messageXML("event", `<Event><![CDATA[unsubscribe]]></Event>`),
+			&UnsubscribeEvent{header(MSG_TYPE_EVENT, EVENT_UNSUBSCRIBE)},
+		},
+		{
+			"scan",
+			messageXML("event", `<Event><![CDATA[SCAN]]></Event><EventKey><![CDATA[123]]></EventKey>`+
+				`<Ticket><![CDATA[tk]]></Ticket>`),
+			&ScanEvent{header(MSG_TYPE_EVENT, EVENT_SCAN), "123", "tk"},
+		},
+		{
+			"location_event",
+			messageXML("event", `<Event><![CDATA[LOCATION]]></Event><Latitude>23.137466</Latitude>`+
+				`<Longitude>113.352425</Longitude><Precision>119.385040</Precision>`),
+			&LocationEvent{header(MSG_TYPE_EVENT, EVENT_LOCATION), 23.137466, 113.352425, 119.385040},
+		},
+		{
+			"click",
+			messageXML("event", `<Event><![CDATA[CLICK]]></Event><EventKey><![CDATA[K]]></EventKey>`),
+			&ClickEvent{header(MSG_TYPE_EVENT, EVENT_CLICK), "K"},
+		},
+		{
+			"view",
+			messageXML("event", `<Event><![CDATA[VIEW]]></Event><EventKey><![CDATA[http://v]]></EventKey>`+
+				`<MenuId>1</MenuId>`),
+			&ViewEvent{header(MSG_TYPE_EVENT, EVENT_VIEW), "http://v", "1"},
+		},
+		{
+			"templatesendjobfinish",
+			messageXML("event", `<Event><![CDATA[TEMPLATESENDJOBFINISH]]></Event><MsgID>200163836</MsgID>`+
+				`<Status><![CDATA[failed:user block]]></Status>`),
+			&TemplateSendJobFinishEvent{header(MSG_TYPE_EVENT, EVENT_TEMPLATESENDJOBFINISH), 200163836, "failed:user block"},
+		},
+		{
+			"subscribe_msg_popup",
+			messageXML("event", `<Event><![CDATA[subscribe_msg_popup_event]]></Event><SubscribeMsgPopupEvent>`+
+				`<List><TemplateId><![CDATA[t1]]></TemplateId><SubscribeStatusString><![CDATA[accept]]></SubscribeStatusString>`+
+				`<PopupScene>2</PopupScene></List>`+
+				`<List><TemplateId><![CDATA[t2]]></TemplateId><SubscribeStatusString><![CDATA[reject]]></SubscribeStatusString>`+
+				`<PopupScene>2</PopupScene></List></SubscribeMsgPopupEvent>`),
+			&SubscribeMsgPopupEvent{header(MSG_TYPE_EVENT, EVENT_SUBSCRIBE_MSG_POPUP), []SubscribeMsgStatus{
+				{"t1", "accept", 2},
+				{"t2", "reject", 2},
+			}},
+		},
+		{
+			"subscribe_msg_change",
+			messageXML("event", `<Event><![CDATA[subscribe_msg_change_event]]></Event><SubscribeMsgChangeEvent>`+
+				`<List><TemplateId><![CDATA[t1]]></TemplateId><SubscribeStatusString><![CDATA[reject]]></SubscribeStatusString>`+
+				`</List></SubscribeMsgChangeEvent>`),
+			&SubscribeMsgChangeEvent{header(MSG_TYPE_EVENT, EVENT_SUBSCRIBE_MSG_CHANGE), []SubscribeMsgStatus{
+				{"t1", "reject", 0},
+			}},
+		},
+		{
+			"unknown_event",
+			messageXML("event", `<Event><![CDATA[kf_create_session]]></Event>`),
+			&UnknownMessage{header(MSG_TYPE_EVENT, "kf_create_session")},
+		},
+	} {
+
+		msg, err := ParseMessage(c.XML)
+
+		if err != nil {
+
+			t.Errorf("%s: %s", c.Name, err)
+
+			continue
+
+		}
+
+		if !reflect.DeepEqual(msg, c.Expect) {
+
+			t.Errorf("%s: expect %+v, got %+v", c.Name, c.Expect, msg)
+
+		}
+
+	}
+
+}
+
+func TestParseMessageError(t *testing.T) {
+
+	for _, data := range []string{
+		``,
+		`<xml><ToUserName>gh</ToUserName></xml>`,
+		`<xml><MsgType>text</MsgType>`,
+		`<xml><MsgType>text</MsgType><MsgId>abc</MsgId></xml>`,
+	} {
+
+		if _, err := ParseMessage([]byte(data)); err == nil {
+
+			t.Errorf("%+q: expect error", data)
+
+		}
+
+	}
+
+}
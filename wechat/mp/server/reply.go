@@ -0,0 +1,185 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// Max number of articles in a news reply.
+const MaxReplyArticles = 8
+
+// CDATA is a string encoded as a CDATA section, as Wechat's docs expect.
+type CDATA string
+
+func (c CDATA) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+
+	return e.EncodeElement(struct {
+		Text string `xml:",cdata"`
+	}{string(c)}, start)
+
+}
+
+// A passive reply. Use Context.Reply to send it.
+type Reply interface {
+	header() *ReplyHeader
+	msgType() string
+	validate() error
+}
+
+// Common part of replies, it's filled by Context.Reply.
+type ReplyHeader struct {
+	ToUserName   CDATA `xml:"ToUserName"`
+	FromUserName CDATA `xml:"FromUserName"`
+	CreateTime   int64 `xml:"CreateTime"`
+	MsgType      CDATA `xml:"MsgType"`
+}
+
+func (h *ReplyHeader) header() *ReplyHeader {
+	return h
+}
+
+func (h *ReplyHeader) validate() error {
+	return nil
+}
+
+type TextReply struct {
+	XMLName xml.Name `xml:"xml"`
+	ReplyHeader
+	Content CDATA `xml:"Content"`
+}
+
+func (r *TextReply) msgType() string {
+	return "text"
+}
+
+type ReplyMedia struct {
+	MediaID CDATA `xml:"MediaId"`
+}
+
+type ImageReply struct {
+	XMLName xml.Name `xml:"xml"`
+	ReplyHeader
+	Image ReplyMedia `xml:"Image"`
+}
+
+func (r *ImageReply) msgType() string {
+	return "image"
+}
+
+type VoiceReply struct {
+	XMLName xml.Name `xml:"xml"`
+	ReplyHeader
+	Voice ReplyMedia `xml:"Voice"`
+}
+
+func (r *VoiceReply) msgType() string {
+	return "voice"
+}
+
+type ReplyVideo struct {
+	MediaID     CDATA `xml:"MediaId"`
+	Title       CDATA `xml:"Title,omitempty"`
+	Description CDATA `xml:"Description,omitempty"`
+}
+
+type VideoReply struct {
+	XMLName xml.Name `xml:"xml"`
+	ReplyHeader
+	Video ReplyVideo `xml:"Video"`
+}
+
+func (r *VideoReply) msgType() string {
+	return "video"
+}
+
+type ReplyMusic struct {
+	Title        CDATA `xml:"Title,omitempty"`
+	Description  CDATA `xml:"Description,omitempty"`
+	MusicURL     CDATA `xml:"MusicUrl,omitempty"`
+	HQMusicURL   CDATA `xml:"HQMusicUrl,omitempty"`
+	ThumbMediaID CDATA `xml:"ThumbMediaId"`
+}
+
+type MusicReply struct {
+	XMLName xml.Name `xml:"xml"`
+	ReplyHeader
+	Music ReplyMusic `xml:"Music"`
+}
+
+func (r *MusicReply) msgType() string {
+	return "music"
+}
+
+type ReplyArticle struct {
+	Title       CDATA `xml:"Title"`
+	Description CDATA `xml:"Description"`
+	PicURL      CDATA `xml:"PicUrl"`
+	URL         CDATA `xml:"Url"`
+}
+
+// News reply. NOTE: when replying to user messages, Wechat only shows 1 article.
+type NewsReply struct {
+	XMLName xml.Name `xml:"xml"`
+	ReplyHeader
+	ArticleCount int            `xml:"ArticleCount"` // Filled by Context.Reply
+	Articles     []ReplyArticle `xml:"Articles>item"`
+}
+
+func (r *NewsReply) msgType() string {
+	return "news"
+}
+
+func (r *NewsReply) validate() error {
+
+	if len(r.Articles) == 0 || len(r.Articles) > MaxReplyArticles {
+
+		return fmt.Errorf("NewsReply: expect 1~%d articles but got %d", MaxReplyArticles, len(r.Articles))
+
+	}
+
+	r.ArticleCount = len(r.Articles)
+
+	return nil
+
+}
+
+type ReplyTransInfo struct {
+	KfAccount CDATA `xml:"KfAccount"`
+}
+
+// Transfer the message to customer service. TransInfo can be nil, otherwise the
+// message is transferred to the specified customer service account.
+type TransferCustomerServiceReply struct {
+	XMLName xml.Name `xml:"xml"`
+	ReplyHeader
+	TransInfo *ReplyTransInfo `xml:"TransInfo,omitempty"`
+}
+
+func (r *TransferCustomerServiceReply) msgType() string {
+	return "transfer_customer_service"
+}
+
+// Encode a reply to the message.
+func encodeReply(msg Message, reply Reply, create_time int64) ([]byte, error) {
+
+	if err := reply.validate(); err != nil {
+
+		return nil, err
+
+	}
+
+	h := reply.header()
+
+	in := msg.Header()
+
+	h.ToUserName = CDATA(in.FromUserName)
+
+	h.FromUserName = CDATA(in.ToUserName)
+
+	h.CreateTime = create_time
+
+	h.MsgType = CDATA(reply.msgType())
+
+	return xml.Marshal(reply)
+
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestEncodeReply(t *testing.T) {
+
+	msg := &TextMessage{MessageHeader: header(MSG_TYPE_TEXT, "")}
+
+	const h = `<ToUserName><![CDATA[u]]></ToUserName><FromUserName><![CDATA[gh]]></FromUserName>` +
+		`<CreateTime>1409735669</CreateTime>`
+
+	for _, c := range []struct {
+		Name   string
+		Reply  Reply
+		Expect string
+	}{
+		{
+			"text",
+			&TextReply{Content: "你好"},
+			`<xml>` + h + `<MsgType><![CDATA[text]]></MsgType><Content><![CDATA[你好]]></Content></xml>`,
+		},
+		{
+			"text_cdata_end",
+			&TextReply{Content: "a]]>b<c>&"},
+			`<xml>` + h + `<MsgType><![CDATA[text]]></MsgType>` +
+				`<Content><![CDATA[a]]]]><![CDATA[>b<c>&]]></Content></xml>`,
+		},
+		{
+			"image",
+			&ImageReply{Image: ReplyMedia{MediaID: "m"}},
+			`<xml>` + h + `<MsgType><![CDATA[image]]></MsgType><Image><MediaId><![CDATA[m]]></MediaId></Image></xml>`,
+		},
+		{
+			"voice",
+			&VoiceReply{Voice: ReplyMedia{MediaID: "m"}},
+			`<xml>` + h + `<MsgType><![CDATA[voice]]></MsgType><Voice><MediaId><![CDATA[m]]></MediaId></Voice></xml>`,
+		},
+		{
+			"video",
+			&VideoReply{Video: ReplyVideo{MediaID: "m", Title: "t", Description: "d"}},
+			`<xml>` + h + `<MsgType><![CDATA[video]]></MsgType><Video><MediaId><![CDATA[m]]></MediaId>` +
+				`<Title><![CDATA[t]]></Title><Description><![CDATA[d]]></Description></Video></xml>`,
+		},
+		{
+			"video_no_title",
+			&VideoReply{Video: ReplyVideo{MediaID: "m"}},
+			`<xml>` + h + `<MsgType><![CDATA[video]]></MsgType><Video><MediaId><![CDATA[m]]></MediaId></Video></xml>`,
+		},
+		{
+			"music",
+			&MusicReply{Music: ReplyMusic{Title: "t", Description: "d", MusicURL: "http://m", HQMusicURL: "http://hq",
+				ThumbMediaID: "th"}},
+			`<xml>` + h + `<MsgType><![CDATA[music]]></MsgType><Music><Title><![CDATA[t]]></Title>` +
+				`<Description><![CDATA[d]]></Description><MusicUrl><![CDATA[http://m]]></MusicUrl>` +
+				`<HQMusicUrl><![CDATA[http://hq]]></HQMusicUrl><ThumbMediaId><![CDATA[th]]></ThumbMediaId></Music></xml>`,
+		},
+		{
+			"news",
+			&NewsReply{Articles: []ReplyArticle{
+				{Title: "t1", Description: "d1", PicURL: "http://p1", URL: "http://u1?a=1&b=2"},
+				{Title: "t2]]>", Description: "d2", PicURL: "http://p2", URL: "http://u2"},
+			}},
+			`<xml>` + h + `<MsgType><![CDATA[news]]></MsgType><ArticleCount>2</ArticleCount><Articles>` +
+				`<item><Title><![CDATA[t1]]></Title><Description><![CDATA[d1]]></Description>` +
+				`<PicUrl><![CDATA[http://p1]]></PicUrl><Url><![CDATA[http://u1?a=1&b=2]]></Url></item>` +
+				`<item><Title><![CDATA[t2]]]]><![CDATA[>]]></Title><Description><![CDATA[d2]]></Description>` +
+				`<PicUrl><![CDATA[http://p2]]></PicUrl><Url><![CDATA[http://u2]]></Url></item></Articles></xml>`,
+		},
+		{
+			"transfer_customer_service",
+			&TransferCustomerServiceReply{},
+			`<xml>` + h + `<MsgType><![CDATA[transfer_customer_service]]></MsgType></xml>`,
+		},
+		{
+			"transfer_customer_service_account",
+			&TransferCustomerServiceReply{TransInfo: &ReplyTransInfo{KfAccount: "test1@test"}},
+			`<xml>` + h + `<MsgType><![CDATA[transfer_customer_service]]></MsgType>` +
+				`<TransInfo><KfAccount><![CDATA[test1@test]]></KfAccount></TransInfo></xml>`,
+		},
+	} {
+
+		data, err := encodeReply(msg, c.Reply, 1409735669)
+
+		if err != nil {
+
+			t.Errorf("%s: %s", c.Name, err)
+
+			continue
+
+		}
+
+		if string(data) != c.Expect {
+
+			t.Errorf("%s:\nexpect %s\ngot    %s", c.Name, c.Expect, data)
+
+		}
+
+		// Wechat parses CDATA, make sure the content round-trips.
+		if text, ok := c.Reply.(*TextReply); ok {
+
+			parsed, err := ParseMessage(data)
+
+			if err != nil {
+
+				t.Errorf("%s: %s", c.Name, err)
+
+				continue
+
+			}
+
+			if content := parsed.(*TextMessage).Content; content != string(text.Content) {
+
+				t.Errorf("%s: expect content %+q, got %+q", c.Name, text.Content, content)
+
+			}
+
+		}
+
+	}
+
+}
+
+func TestEncodeReplyNewsArticles(t *testing.T) {
+
+	msg := &TextMessage{MessageHeader: header(MSG_TYPE_TEXT, "")}
+
+	for _, n := range []int{0, MaxReplyArticles + 1} {
+
+		if _, err := encodeReply(msg, &NewsReply{Articles: make([]ReplyArticle, n)}, 0); err == nil {
+
+			t.Errorf("expect error for %d articles", n)
+
+		}
+
+	}
+
+	if _, err := encodeReply(msg, &NewsReply{Articles: make([]ReplyArticle, MaxReplyArticles)}, 0); err != nil {
+
+		t.Error(err)
+
+	}
+
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"net/http"
+)
+
+// Context of handling an inbound message.
+type Context struct {
+	context.Context
+
+	// The HTTP request.
+	Request *http.Request
+
+	// The parsed message.
+	Message Message
+
+	// The (decrypted) XML of the message.
+	RawXML []byte
+
+	// Whether the message was encrypted, if so the reply will be encrypted too.
+	Encrypted bool
+
+	reply Reply
+}
+
+// Set the passive reply to the message, it must be sent within 5 seconds. Passing
+// nil clears it and the empty reply "success" is sent.
+func (c *Context) Reply(reply Reply) {
+
+	c.reply = reply
+
+}
+
+// Handle an inbound message. Returning error makes the server respond 500 (and
+// Wechat will retry).
+type Handler interface {
+	ServeMessage(c *Context) error
+}
+
+// Adapter to use an ordinary function as Handler.
+type HandlerFunc func(c *Context) error
+
+func (f HandlerFunc) ServeMessage(c *Context) error {
+	return f(c)
+}
+
+// Router dispatches messages to handlers by MsgType, and events to handlers by
+// Event. Messages/events without a registered handler are passed to Default (if
+// any) or ignored.
+type Router struct {
+	messages map[MsgType]Handler
+	events   map[EventType]Handler
+
+	// Handler for messages/events not registered, can be nil.
+	Default Handler
+}
+
+// Create an empty router.
+func NewRouter() *Router {
+
+	return &Router{
+		messages: make(map[MsgType]Handler),
+		events:   make(map[EventType]Handler),
+	}
+
+}
+
+// Register a handler for messages of msg_type (not MSG_TYPE_EVENT, use HandleEvent instead).
+func (r *Router) HandleMessage(msg_type MsgType, h Handler) {
+
+	if msg_type == MSG_TYPE_EVENT {
+
+		panic("HandleMessage: use HandleEvent for events")
+
+	}
+
+	r.messages[msg_type] = h
+
+}
+
+// Register a handler function for messages of msg_type.
+func (r *Router) HandleMessageFunc(msg_type MsgType, f func(c *Context) error) {
+
+	r.HandleMessage(msg_type, HandlerFunc(f))
+
+}
+
+// Register a handler for events of event_type.
+func (r *Router) HandleEvent(event_type EventType, h Handler) {
+
+	r.events[event_type] = h
+
+}
+
+// Register a handler function for events of event_type.
+func (r *Router) HandleEventFunc(event_type EventType, f func(c *Context) error) {
+
+	r.HandleEvent(event_type, HandlerFunc(f))
+
+}
+
+// Implement Handler.
+func (r *Router) ServeMessage(c *Context) error {
+
+	header := c.Message.Header()
+
+	var (
+		h  Handler
+		ok bool
+	)
+
+	if header.MsgType == MSG_TYPE_EVENT {
+
+		h, ok = r.events[header.Event]
+
+	} else {
+
+		h, ok = r.messages[header.MsgType]
+
+	}
+
+	if !ok {
+
+		h = r.Default
+
+	}
+
+	if h == nil {
+
+		return nil
+
+	}
+
+	return h.ServeMessage(c)
+
+}
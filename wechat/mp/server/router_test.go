@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+
+	var called string
+
+	handler := func(name string) func(c *Context) error {
+
+		return func(c *Context) error {
+
+			called = name
+
+			return nil
+
+		}
+
+	}
+
+	r := NewRouter()
+
+	r.HandleMessageFunc(MSG_TYPE_TEXT, handler("text"))
+
+	r.HandleEventFunc(EVENT_CLICK, handler("click"))
+
+	r.HandleEventFunc(EVENT_SUBSCRIBE, handler("subscribe"))
+
+	serve := func(msg Message) error {
+
+		called = ""
+
+		return r.ServeMessage(&Context{Context: context.Background(), Message: msg})
+
+	}
+
+	for _, c := range []struct {
+		Name          string
+		Message       Message
+		Expect        string
+		ExpectDefault string
+	}{
+		{"text", &TextMessage{MessageHeader: header(MSG_TYPE_TEXT, "")}, "text", "text"},
+		{"click", &ClickEvent{MessageHeader: header(MSG_TYPE_EVENT, EVENT_CLICK)}, "click", "click"},
+		{"subscribe", &SubscribeEvent{MessageHeader: header(MSG_TYPE_EVENT, EVENT_SUBSCRIBE)}, "subscribe", "subscribe"},
+		{"image", &ImageMessage{MessageHeader: header(MSG_TYPE_IMAGE, "")}, "", "default"},
+		{"view", &ViewEvent{MessageHeader: header(MSG_TYPE_EVENT, EVENT_VIEW)}, "", "default"},
+		{"unknown", &UnknownMessage{header(MSG_TYPE_EVENT, "kf_create_session")}, "", "default"},
+		// An event whose Event equals a registered MsgType is not dispatched as message.
+		{"event_named_text", &UnknownMessage{header(MSG_TYPE_EVENT, "text")}, "", "default"},
+	} {
+
+		r.Default = nil
+
+		if err := serve(c.Message); err != nil || called != c.Expect {
+
+			t.Errorf("%s: expect %+q called, got %+q %v", c.Name, c.Expect, called, err)
+
+		}
+
+		r.Default = HandlerFunc(handler("default"))
+
+		if err := serve(c.Message); err != nil || called != c.ExpectDefault {
+
+			t.Errorf("%s: expect %+q called with default, got %+q %v", c.Name, c.ExpectDefault, called, err)
+
+		}
+
+	}
+
+	// Errors are passed through.
+	expect := errors.New("handler error")
+
+	r.HandleMessageFunc(MSG_TYPE_TEXT, func(c *Context) error { return expect })
+
+	if err := serve(&TextMessage{MessageHeader: header(MSG_TYPE_TEXT, "")}); err != expect {
+
+		t.Fatalf("expect handler error, got %v", err)
+
+	}
+
+}
+
+func TestRouterHandleMessageEvent(t *testing.T) {
+
+	defer func() {
+
+		if recover() == nil {
+
+			t.Fatal("expect panic for HandleMessage(MSG_TYPE_EVENT)")
+
+		}
+
+	}()
+
+	NewRouter().HandleMessageFunc(MSG_TYPE_EVENT, func(c *Context) error { return nil })
+
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Prefix of EventKey in SubscribeEvent from parametric QR codes.
+const qrScenePrefix = "qrscene_"
+
+var digitsRegexp = regexp.MustCompile(`^[0-9]+$`)
+
+// Scene value of a parametric QR code, either an integer id (QR_SCENE,
+// QR_LIMIT_SCENE) or a string (QR_STR_SCENE, QR_LIMIT_STR_SCENE). Since Wechat
+// reports both in the same EventKey, string scenes must not be all digits so that
+// they round-trip.
+type QRScene struct {
+	ID  uint32
+	Str string
+}
+
+// Is it an integer scene ?
+func (s QRScene) IsID() bool {
+	return s.Str == ""
+}
+
+func (s QRScene) String() string {
+
+	if s.IsID() {
+
+		return strconv.FormatUint(uint64(s.ID), 10)
+
+	}
+
+	return s.Str
+
+}
+
+// Check the scene value, permanent integer scenes are limited to 1~100000.
+func (s QRScene) Validate(permanent bool) error {
+
+	if s.IsID() {
+
+		if s.ID == 0 {
+
+			return fmt.Errorf("QRScene: scene id should not be 0")
+
+		}
+
+		if permanent && s.ID > 100000 {
+
+			return fmt.Errorf("QRScene: permanent scene id should be 1~100000")
+
+		}
+
+		return nil
+
+	}
+
+	if len(s.Str) > 64 {
+
+		return fmt.Errorf("QRScene: scene str should be 1~64 chars")
+
+	}
+
+	if digitsRegexp.MatchString(s.Str) {
+
+		return fmt.Errorf("QRScene: scene str %+q should not be all digits", s.Str)
+
+	}
+
+	return nil
+
+}
+
+// Parse scene from EventKey of SCAN events (or subscribe events with prefix trimmed).
+func ParseQRScene(event_key string) (QRScene, error) {
+
+	if event_key == "" {
+
+		return QRScene{}, fmt.Errorf("ParseQRScene: empty EventKey")
+
+	}
+
+	if digitsRegexp.MatchString(event_key) {
+
+		id, err := strconv.ParseUint(event_key, 10, 32)
+
+		if err != nil {
+
+			return QRScene{}, fmt.Errorf("ParseQRScene: %s", err)
+
+		}
+
+		return QRScene{ID: uint32(id)}, nil
+
+	}
+
+	return QRScene{Str: event_key}, nil
+
+}
+
+// Return the scene if the user subscribed by scanning a parametric QR code.
+func (e *SubscribeEvent) QRScene() (QRScene, bool) {
+
+	if !strings.HasPrefix(e.EventKey, qrScenePrefix) {
+
+		return QRScene{}, false
+
+	}
+
+	scene, err := ParseQRScene(strings.TrimPrefix(e.EventKey, qrScenePrefix))
+
+	return scene, err == nil
+
+}
+
+// Return the scene of the QR code scanned.
+func (e *ScanEvent) QRScene() (QRScene, bool) {
+
+	scene, err := ParseQRScene(e.EventKey)
+
+	return scene, err == nil
+
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestQRSceneEvents(t *testing.T) {
+
+	for _, scene := range []QRScene{
+		{ID: 1},
+		{ID: 100000},
+		{ID: 4294967295},
+		{Str: "promo_2024"},
+		{Str: "a1"},
+	} {
+
+		// Subscribing by scanning: EventKey is "qrscene_" prefixed.
+		msg, err := ParseMessage(messageXML("event", `<Event><![CDATA[subscribe]]></Event>`+
+			`<EventKey><![CDATA[qrscene_`+scene.String()+`]]></EventKey><Ticket><![CDATA[tk]]></Ticket>`))
+
+		if err != nil {
+
+			t.Fatal(err)
+
+		}
+
+		got, ok := msg.(*SubscribeEvent).QRScene()
+
+		if !ok || got != scene {
+
+			t.Errorf("subscribe %+v: got %+v %v", scene, got, ok)
+
+		}
+
+		// Subscribed user scanning: bare EventKey.
+		msg, err = ParseMessage(messageXML("event", `<Event><![CDATA[SCAN]]></Event>`+
+			`<EventKey><![CDATA[`+scene.String()+`]]></EventKey><Ticket><![CDATA[tk]]></Ticket>`))
+
+		if err != nil {
+
+			t.Fatal(err)
+
+		}
+
+		got, ok = msg.(*ScanEvent).QRScene()
+
+		if !ok || got != scene {
+
+			t.Errorf("SCAN %+v: got %+v %v", scene, got, ok)
+
+		}
+
+	}
+
+	// Not from a parametric QR code.
+	for _, event_key := range []string{"", "123", "last_trade_no"} {
+
+		msg, err := ParseMessage(messageXML("event", `<Event><![CDATA[subscribe]]></Event>`+
+			`<EventKey><![CDATA[`+event_key+`]]></EventKey>`))
+
+		if err != nil {
+
+			t.Fatal(err)
+
+		}
+
+		if scene, ok := msg.(*SubscribeEvent).QRScene(); ok {
+
+			t.Errorf("%+q: expect no scene, got %+v", event_key, scene)
+
+		}
+
+	}
+
+	// Out of range or empty.
+	for _, event_key := range []string{"", "4294967296"} {
+
+		msg, err := ParseMessage(messageXML("event", `<Event><![CDATA[SCAN]]></Event>`+
+			`<EventKey><![CDATA[`+event_key+`]]></EventKey>`))
+
+		if err != nil {
+
+			t.Fatal(err)
+
+		}
+
+		if scene, ok := msg.(*ScanEvent).QRScene(); ok {
+
+			t.Errorf("%+q: expect no scene, got %+v", event_key, scene)
+
+		}
+
+	}
+
+}
+
+func TestQRSceneValidate(t *testing.T) {
+
+	for _, c := range []struct {
+		Scene     QRScene
+		Permanent bool
+		OK        bool
+	}{
+		{QRScene{ID: 1}, true, true},
+		{QRScene{ID: 100000}, true, true},
+		{QRScene{ID: 100001}, true, false},
+		{QRScene{ID: 100001}, false, true},
+		{QRScene{}, false, false},
+		{QRScene{Str: "a"}, true, true},
+		{QRScene{Str: "123"}, false, false},
+		{QRScene{Str: string(make([]byte, 65))}, false, false},
+	} {
+
+		if err := c.Scene.Validate(c.Permanent); (err == nil) != c.OK {
+
+			t.Errorf("%+v permanent=%v: expect ok=%v, got %v", c.Scene, c.Permanent, c.OK, err)
+
+		}
+
+	}
+
+}
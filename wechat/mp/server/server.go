@@ -0,0 +1,321 @@
+// Package server implements the callback side of an official account: it verifies
+// requests from Wechat, parses inbound messages/events and dispatches them to
+// handlers.
+package server
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/msgcrypt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default value of Server.MaxTimestampSkew.
+const DefaultMaxTimestampSkew = 5 * time.Minute
+
+// Message encryption mode configured in MP console.
+type Mode int
+
+const (
+	// Messages are not encrypted.
+	MODE_PLAIN Mode = iota
+	// Messages are sent in both plain and encrypted forms.
+	MODE_COMPATIBLE
+	// Messages are encrypted.
+	MODE_SAFE
+)
+
+// Server is a http.Handler serving Wechat's message callbacks.
+type Server struct {
+	// The token configured in MP console.
+	token string
+
+	// Handler of messages.
+	handler Handler
+
+	// Encryption mode. Default to MODE_PLAIN. Crypt is required for other modes.
+	Mode Mode
+
+	// Used to decrypt messages (and encrypt replies) in compatible/safe mode.
+	Crypt *msgcrypt.MsgCrypt
+
+	// Source of current time. Default to wx.SystemClock.
+	Clock wx.Clock
+
+	// Requests whose timestamp differs from current time more than this are
+	// rejected (against replay). Default to DefaultMaxTimestampSkew.
+	MaxTimestampSkew time.Duration
+
+	// Max size of request body. Default to 64k.
+	MaxBodySize int
+
+	// Logger, can be nil.
+	Logger wx.Logger
+}
+
+// Create a Server with the token configured in MP console and the message handler
+// (usually a Router).
+func NewServer(token string, handler Handler) (*Server, error) {
+
+	if token == "" {
+
+		return nil, fmt.Errorf("NewServer: token missing")
+
+	}
+
+	if handler == nil {
+
+		return nil, fmt.Errorf("NewServer: handler missing")
+
+	}
+
+	return &Server{
+		token:   token,
+		handler: handler,
+	}, nil
+
+}
+
+// Signature of callback requests: sha1 of sorted token/timestamp/nonce concatenated.
+func Signature(token, timestamp, nonce string) string {
+
+	strs := sort.StringSlice{token, timestamp, nonce}
+
+	strs.Sort()
+
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(strs, ""))))
+
+}
+
+// Verify the "signature" of a callback request.
+func (s *Server) VerifySignature(r *http.Request) bool {
+
+	q := r.URL.Query()
+
+	expect := Signature(s.token, q.Get("timestamp"), q.Get("nonce"))
+
+	return subtle.ConstantTimeCompare([]byte(expect), []byte(q.Get("signature"))) == 1
+
+}
+
+// Is the "timestamp" of a callback request within MaxTimestampSkew of current time ?
+func (s *Server) VerifyTimestamp(r *http.Request) bool {
+
+	timestamp, err := strconv.ParseInt(r.URL.Query().Get("timestamp"), 10, 64)
+
+	if err != nil {
+
+		return false
+
+	}
+
+	max_skew := s.MaxTimestampSkew
+
+	if max_skew <= 0 {
+
+		max_skew = DefaultMaxTimestampSkew
+
+	}
+
+	skew := wx.Now(s.Clock).Sub(time.Unix(timestamp, 0))
+
+	return skew <= max_skew && skew >= -max_skew
+
+}
+
+func (s *Server) maxBodySize() int {
+
+	max_body_size := s.MaxBodySize
+
+	if max_body_size <= 0 {
+
+		max_body_size = 64 * 1024
+
+	}
+
+	return max_body_size
+
+}
+
+func (s *Server) logf(format string, v ...interface{}) {
+
+	if s.Logger != nil {
+
+		s.Logger.Printf(format, v...)
+
+	}
+
+}
+
+// Implement http.Handler. GET requests are the URL verification handshake, POST
+// requests carry messages.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if !s.VerifySignature(r) {
+
+		s.logf("bad_signature url=%+q\n", r.URL.String())
+		http.Error(w, "bad signature", http.StatusForbidden)
+		return
+
+	}
+
+	if !s.VerifyTimestamp(r) {
+
+		s.logf("stale_timestamp url=%+q\n", r.URL.String())
+		http.Error(w, "stale timestamp", http.StatusForbidden)
+		return
+
+	}
+
+	switch r.Method {
+
+	case "GET":
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, r.URL.Query().Get("echostr"))
+		return
+
+	case "POST":
+
+	default:
+
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+
+	}
+
+	var body bytes.Buffer
+
+	if err := wx.LimitRead(r.Body, &body, int64(s.maxBodySize())); err != nil {
+
+		s.logf("read_error=%+q\n", err.Error())
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+
+	}
+
+	s.logf("message_body=%+q\n", body.Bytes())
+
+	raw_xml, encrypted, err := s.decrypt(r, body.Bytes())
+
+	if err != nil {
+
+		s.logf("decrypt_error=%+q\n", err.Error())
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+
+	}
+
+	msg, err := ParseMessage(raw_xml)
+
+	if err != nil {
+
+		s.logf("parse_error=%+q\n", err.Error())
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+
+	}
+
+	c := &Context{
+		Context:   r.Context(),
+		Request:   r,
+		Message:   msg,
+		RawXML:    raw_xml,
+		Encrypted: encrypted,
+	}
+
+	if err := s.handler.ServeMessage(c); err != nil {
+
+		s.logf("handler_error=%+q\n", err.Error())
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+
+	}
+
+	if c.reply == nil {
+
+		io.WriteString(w, "success")
+		return
+
+	}
+
+	reply, err := s.encodeReply(c)
+
+	if err != nil {
+
+		s.logf("reply_error=%+q\n", err.Error())
+		// Still respond "success", otherwise Wechat shows an error to the user.
+		io.WriteString(w, "success")
+		return
+
+	}
+
+	s.logf("reply_body=%+q\n", reply)
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+
+	w.Write(reply)
+
+}
+
+// Encode (and encrypt if the message was encrypted) the reply.
+func (s *Server) encodeReply(c *Context) ([]byte, error) {
+
+	now := wx.Now(s.Clock).Unix()
+
+	reply, err := encodeReply(c.Message, c.reply, now)
+
+	if err != nil || !c.Encrypted {
+
+		return reply, err
+
+	}
+
+	return s.Crypt.EncryptReply(reply, now, c.Request.URL.Query().Get("nonce"))
+
+}
+
+// Decrypt the request body if needed. Return the plain XML and whether it was encrypted.
+func (s *Server) decrypt(r *http.Request, body []byte) ([]byte, bool, error) {
+
+	q := r.URL.Query()
+
+	if q.Get("encrypt_type") != "aes" {
+
+		if s.Mode == MODE_SAFE {
+
+			return nil, false, fmt.Errorf("plain message in safe mode")
+
+		}
+
+		return body, false, nil
+
+	}
+
+	if s.Mode == MODE_PLAIN || s.Crypt == nil {
+
+		return nil, false, fmt.Errorf("encrypted message but no Crypt configured")
+
+	}
+
+	plain, err := s.Crypt.DecryptMessage(body, q.Get("msg_signature"), q.Get("timestamp"), q.Get("nonce"))
+
+	if err != nil {
+
+		return nil, false, err
+
+	}
+
+	s.logf("decrypted_body=%+q\n", plain)
+
+	return plain, true, nil
+
+}
@@ -0,0 +1,342 @@
+package server
+
+import (
+	"encoding/xml"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/msgcrypt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeHTTPVerification(t *testing.T) {
+
+	s, err := NewServer("token", HandlerFunc(func(c *Context) error { return nil }))
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	now := time.Unix(1409735669, 0)
+
+	s.Clock = wx.ClockFunc(func() time.Time { return now })
+
+	for _, c := range []struct {
+		Name      string
+		Timestamp string
+		Status    int
+	}{
+		{"fresh", "1409735669", http.StatusOK},
+		{"slightly_ahead", "1409735729", http.StatusOK},
+		{"stale", "1409735069", http.StatusForbidden},
+		{"too_ahead", "1409736269", http.StatusForbidden},
+		{"malformed", "abc", http.StatusForbidden},
+	} {
+
+		q := url.Values{
+			"timestamp": []string{c.Timestamp},
+			"nonce":     []string{"1320562132"},
+			"signature": []string{Signature("token", c.Timestamp, "1320562132")},
+			"echostr":   []string{"hello"},
+		}
+
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/?"+q.Encode(), nil))
+
+		if w.Code != c.Status {
+
+			t.Errorf("%s: expect status %d, got %d", c.Name, c.Status, w.Code)
+
+			continue
+
+		}
+
+		if c.Status != http.StatusOK {
+
+			continue
+
+		}
+
+		if ct := w.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+
+			t.Errorf("%s: unexpected Content-Type %+q", c.Name, ct)
+
+		}
+
+		if body := w.Body.String(); body != "hello" {
+
+			t.Errorf("%s: unexpected body %+q", c.Name, body)
+
+		}
+
+	}
+
+}
+
+// Query of a callback request signed with "token".
+func callbackQuery(timestamp, nonce string) url.Values {
+
+	return url.Values{
+		"timestamp": []string{timestamp},
+		"nonce":     []string{nonce},
+		"signature": []string{Signature("token", timestamp, nonce)},
+	}
+
+}
+
+func TestServeHTTPPost(t *testing.T) {
+
+	var received Message
+
+	s, err := NewServer("token", HandlerFunc(func(c *Context) error {
+
+		received = c.Message
+
+		return nil
+
+	}))
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	s.Clock = wx.ClockFunc(func() time.Time { return time.Unix(1409735669, 0) })
+
+	body := string(messageXML("text", `<Content><![CDATA[hello]]></Content><MsgId>1</MsgId>`))
+
+	for _, c := range []struct {
+		Name   string
+		Query  url.Values
+		Status int
+	}{
+		{"fresh", callbackQuery("1409735669", "1"), http.StatusOK},
+		{"stale", callbackQuery("1409735069", "1"), http.StatusForbidden},
+		{"bad_signature", url.Values{
+			"timestamp": []string{"1409735669"},
+			"nonce":     []string{"1"},
+			"signature": []string{Signature("token", "1409735069", "1")},
+		}, http.StatusForbidden},
+		{"encrypted_in_plain_mode", func() url.Values {
+			q := callbackQuery("1409735669", "1")
+			q.Set("encrypt_type", "aes")
+			return q
+		}(), http.StatusBadRequest},
+	} {
+
+		received = nil
+
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/?"+c.Query.Encode(), strings.NewReader(body)))
+
+		if w.Code != c.Status {
+
+			t.Errorf("%s: expect status %d, got %d", c.Name, c.Status, w.Code)
+
+			continue
+
+		}
+
+		if c.Status != http.StatusOK {
+
+			if received != nil {
+
+				t.Errorf("%s: handler should not be called", c.Name)
+
+			}
+
+			continue
+
+		}
+
+		if msg, ok := received.(*TextMessage); !ok || msg.Content != "hello" {
+
+			t.Errorf("%s: unexpected message %+v", c.Name, received)
+
+		}
+
+		if w.Body.String() != "success" {
+
+			t.Errorf("%s: unexpected body %+q", c.Name, w.Body.String())
+
+		}
+
+	}
+
+}
+
+// Vectors from Wechat's reference WXBizMsgCrypt sample, see msgcrypt's tests.
+const (
+	refToken          = "spamtest"
+	refEncodingAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+	refAppID          = "wx2c2769f8efd9abc2"
+	refTimestamp      = "1409735669"
+	refNonce          = "1320562132"
+	refMsgSignature   = "5d197aaffba7e9b25a30732f161a50dee96bd5fa"
+	refEncrypt        = "hyzAe4OzmOMbd6TvGdIOO6uBmdJoD0Fk53REIHvxYtJlE2B655HuD0m8KUePWB3+LrPXo87wzQ1QLvbeUgmBM4x6F8PGHQHFVAFmOD2LdJF9FrXpbUAh0B5GIItb52sn896wVsMSHGuPE328HnRGBcrS7C41IzDWyWNlZkyyXwon8T332jisa+h6tEDYsVticbSnyU8dKOIbgU6ux5VTjg3yt+WGzjlpKn6NPhRjpA912xMezR4kw6KWwMrCVKSVCZciVGCgavjIQ6X8tCOp3yZbGpy0VxpAe+77TszTfRd5RJSVO/HTnifJpXgCSUdUue1v6h0EIBYYI1BD1DlD+C0CR8e6OewpusjZ4uBl9FyJvnhvQl+q5rv1ixrcpCumEPo5MJSgM9ehVsNPfUM669WuMyVWQLCzpu9GhglF2PE="
+)
+
+func newRefMsgCrypt(t *testing.T) *msgcrypt.MsgCrypt {
+
+	mc, err := msgcrypt.NewMsgCrypt(refToken, refEncodingAESKey, refAppID)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	return mc
+
+}
+
+func TestServeHTTPSafeMode(t *testing.T) {
+
+	var received *Context
+
+	s, err := NewServer(refToken, HandlerFunc(func(c *Context) error {
+
+		received = c
+
+		c.Reply(&TextReply{Content: "hi ]]> there"})
+
+		return nil
+
+	}))
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	s.Mode = MODE_SAFE
+
+	s.Crypt = newRefMsgCrypt(t)
+
+	s.Clock = wx.ClockFunc(func() time.Time { return time.Unix(1409735669, 0) })
+
+	body := "<xml><ToUserName><![CDATA[gh_10f6c3c3ac5a]]></ToUserName><Encrypt><![CDATA[" +
+		refEncrypt + "]]></Encrypt></xml>"
+
+	post := func(q url.Values, body string) *httptest.ResponseRecorder {
+
+		received = nil
+
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/?"+q.Encode(), strings.NewReader(body)))
+
+		return w
+
+	}
+
+	q := url.Values{
+		"timestamp":     []string{refTimestamp},
+		"nonce":         []string{refNonce},
+		"signature":     []string{Signature(refToken, refTimestamp, refNonce)},
+		"encrypt_type":  []string{"aes"},
+		"msg_signature": []string{refMsgSignature},
+	}
+
+	w := post(q, body)
+
+	if w.Code != http.StatusOK {
+
+		t.Fatalf("expect status 200, got %d", w.Code)
+
+	}
+
+	// The decrypted message.
+	msg, ok := received.Message.(*TextMessage)
+
+	if !ok || !received.Encrypted || msg.Content != "abcdteT" || msg.MsgID != 6054768590064713728 ||
+		msg.FromUserName != "oyORnuP8q7ou2gfYjqLzSIWZf0rs" || msg.ToUserName != "gh_10f6c3c3ac5a" {
+
+		t.Fatalf("unexpected message %+v", received.Message)
+
+	}
+
+	// The encrypted reply, decrypted with another instance of the reference config.
+	env := &struct {
+		Encrypt      string `xml:"Encrypt"`
+		MsgSignature string `xml:"MsgSignature"`
+		TimeStamp    string `xml:"TimeStamp"`
+		Nonce        string `xml:"Nonce"`
+	}{}
+
+	if err := xml.Unmarshal(w.Body.Bytes(), env); err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if env.TimeStamp != refTimestamp || env.Nonce != refNonce ||
+		env.MsgSignature != msgcrypt.Signature(refToken, env.TimeStamp, env.Nonce, env.Encrypt) {
+
+		t.Fatalf("unexpected reply envelope %s", w.Body.Bytes())
+
+	}
+
+	plain, err := newRefMsgCrypt(t).Decrypt(env.Encrypt)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	expect := "<xml><ToUserName><![CDATA[oyORnuP8q7ou2gfYjqLzSIWZf0rs]]></ToUserName>" +
+		"<FromUserName><![CDATA[gh_10f6c3c3ac5a]]></FromUserName><CreateTime>1409735669</CreateTime>" +
+		"<MsgType><![CDATA[text]]></MsgType><Content><![CDATA[hi ]]]]><![CDATA[> there]]></Content></xml>"
+
+	if string(plain) != expect {
+
+		t.Fatalf("unexpected reply %s", plain)
+
+	}
+
+	// Rejected requests.
+	for _, c := range []struct {
+		Name   string
+		Query  func(q url.Values)
+		Body   string
+		Status int
+	}{
+		{"bad_msg_signature", func(q url.Values) { q.Set("msg_signature", Signature(refToken, refTimestamp, refNonce)) },
+			body, http.StatusBadRequest},
+		{"plain_message", func(q url.Values) { q.Del("encrypt_type") },
+			string(messageXML("text", `<Content><![CDATA[hello]]></Content>`)), http.StatusBadRequest},
+		{"stale", func(q url.Values) {
+			q.Set("timestamp", "1409735069")
+			q.Set("signature", Signature(refToken, "1409735069", refNonce))
+		}, body, http.StatusForbidden},
+	} {
+
+		bad_q := url.Values{}
+
+		for k, v := range q {
+
+			bad_q[k] = v
+
+		}
+
+		c.Query(bad_q)
+
+		if w := post(bad_q, c.Body); w.Code != c.Status || received != nil {
+
+			t.Errorf("%s: expect status %d, got %d", c.Name, c.Status, w.Code)
+
+		}
+
+	}
+
+}
@@ -0,0 +1,251 @@
+package mp
+
+import (
+	"context"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"unicode/utf8"
+)
+
+// Limits of tag APIs.
+const (
+	MaxTagNameChars   = 30
+	MaxBatchTagging   = 50
+	MaxBatchBlacklist = 20
+)
+
+// Errcode when the tag name already exists.
+const ERRCODE_TAG_EXISTED = 45157
+
+func validateTagName(name string) error {
+
+	if name == "" || utf8.RuneCountInString(name) > MaxTagNameChars {
+
+		return fmt.Errorf("Tag: name should be 1~%d chars", MaxTagNameChars)
+
+	}
+
+	return nil
+
+}
+
+type Tag struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Number of users, only returned by GetTags.
+	Count int `json:"count,omitempty"`
+}
+
+type tagResult struct {
+	wx.ResultBase
+
+	Tag Tag `json:"tag"`
+}
+
+// Create a tag, return it with id.
+func (mp *MP) CreateTag(ctx context.Context, name string, l wx.Logger) (*Tag, error) {
+
+	if err := validateTagName(name); err != nil {
+
+		return nil, err
+
+	}
+
+	r := &tagResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/tags/create",
+		map[string]interface{}{
+			"tag": map[string]string{
+				"name": name,
+			},
+		}, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return &r.Tag, nil
+
+}
+
+type getTagsResult struct {
+	wx.ResultBase
+
+	Tags []Tag `json:"tags"`
+}
+
+// List all tags.
+func (mp *MP) GetTags(ctx context.Context, l wx.Logger) ([]Tag, error) {
+
+	r := &getTagsResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/tags/get", nil, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r.Tags, nil
+
+}
+
+// Rename a tag.
+func (mp *MP) UpdateTag(ctx context.Context, id int, name string, l wx.Logger) error {
+
+	if err := validateTagName(name); err != nil {
+
+		return err
+
+	}
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/tags/update",
+		map[string]interface{}{
+			"tag": &Tag{
+				ID:   id,
+				Name: name,
+			},
+		}, nil, l)
+
+}
+
+// Delete a tag.
+func (mp *MP) DeleteTag(ctx context.Context, id int, l wx.Logger) error {
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/tags/delete",
+		map[string]interface{}{
+			"tag": map[string]int{
+				"id": id,
+			},
+		}, nil, l)
+
+}
+
+// Iterate users of a tag starting after next_openid (empty to start from the beginning).
+func (mp *MP) TagUsers(ctx context.Context, tagid int, next_openid string, l wx.Logger) *OpenIDIterator {
+
+	return newOpenIDIterator(ctx, next_openid, l, func(ctx context.Context, next_openid string,
+		l wx.Logger) (*openIDPage, error) {
+
+		r := &openIDPage{}
+
+		if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/user/tag/get",
+			map[string]interface{}{
+				"tagid":       tagid,
+				"next_openid": next_openid,
+			}, r, l); err != nil {
+
+			return nil, err
+
+		}
+
+		return r, nil
+
+	})
+
+}
+
+func (mp *MP) batchTag(ctx context.Context, URL string, tagid int, openids []string, l wx.Logger) error {
+
+	if len(openids) == 0 || len(openids) > MaxBatchTagging {
+
+		return fmt.Errorf("Tagging: expect 1~%d openids but got %d", MaxBatchTagging, len(openids))
+
+	}
+
+	return mp.tokens.CallAPI(ctx, URL, map[string]interface{}{
+		"openid_list": openids,
+		"tagid":       tagid,
+	}, nil, l)
+
+}
+
+// Tag users (at most 50).
+func (mp *MP) BatchTagging(ctx context.Context, tagid int, openids []string, l wx.Logger) error {
+
+	return mp.batchTag(ctx, "https://api.weixin.qq.com/cgi-bin/tags/members/batchtagging", tagid, openids, l)
+
+}
+
+// Untag users (at most 50).
+func (mp *MP) BatchUntagging(ctx context.Context, tagid int, openids []string, l wx.Logger) error {
+
+	return mp.batchTag(ctx, "https://api.weixin.qq.com/cgi-bin/tags/members/batchuntagging", tagid, openids, l)
+
+}
+
+type getTagIDListResult struct {
+	wx.ResultBase
+
+	TagIDList []int `json:"tagid_list"`
+}
+
+// Get ids of tags of a user.
+func (mp *MP) GetUserTagIDs(ctx context.Context, openid string, l wx.Logger) ([]int, error) {
+
+	r := &getTagIDListResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/tags/getidlist",
+		map[string]string{
+			"openid": openid,
+		}, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r.TagIDList, nil
+
+}
+
+// --- Blacklist
+
+// Iterate blacklisted users starting after begin_openid (empty to start from the beginning).
+func (mp *MP) Blacklist(ctx context.Context, begin_openid string, l wx.Logger) *OpenIDIterator {
+
+	return newOpenIDIterator(ctx, begin_openid, l, func(ctx context.Context, next_openid string,
+		l wx.Logger) (*openIDPage, error) {
+
+		r := &openIDPage{}
+
+		if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/tags/members/getblacklist",
+			map[string]string{
+				"begin_openid": next_openid,
+			}, r, l); err != nil {
+
+			return nil, err
+
+		}
+
+		return r, nil
+
+	})
+
+}
+
+func (mp *MP) batchBlacklist(ctx context.Context, URL string, openids []string, l wx.Logger) error {
+
+	if len(openids) == 0 || len(openids) > MaxBatchBlacklist {
+
+		return fmt.Errorf("Blacklist: expect 1~%d openids but got %d", MaxBatchBlacklist, len(openids))
+
+	}
+
+	return mp.tokens.CallAPI(ctx, URL, map[string]interface{}{
+		"openid_list": openids,
+	}, nil, l)
+
+}
+
+// Blacklist users (at most 20).
+func (mp *MP) BatchBlacklist(ctx context.Context, openids []string, l wx.Logger) error {
+
+	return mp.batchBlacklist(ctx, "https://api.weixin.qq.com/cgi-bin/tags/members/batchblacklist", openids, l)
+
+}
+
+// Remove users from blacklist (at most 20).
+func (mp *MP) BatchUnblacklist(ctx context.Context, openids []string, l wx.Logger) error {
+
+	return mp.batchBlacklist(ctx, "https://api.weixin.qq.com/cgi-bin/tags/members/batchunblacklist", openids, l)
+
+}
@@ -0,0 +1,182 @@
+package mp
+
+import (
+	"context"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+)
+
+// Value (and optional color, e.g. "#173177") of a template keyword.
+type TemplateDataItem struct {
+	Value string `json:"value"`
+	Color string `json:"color,omitempty"`
+}
+
+// Keyword name (e.g. "first", "keyword1", "remark") -> value.
+type TemplateData map[string]TemplateDataItem
+
+// Jump to a mini program when the message is clicked.
+type TemplateMiniProgram struct {
+	AppID    string `json:"appid"`
+	PagePath string `json:"pagepath,omitempty"`
+}
+
+type TemplateMessage struct {
+	// --- Required
+	ToUser     string       `json:"touser"`
+	TemplateID string       `json:"template_id"`
+	Data       TemplateData `json:"data"`
+
+	// --- Optional, mini program jump takes precedence over URL
+	URL         string               `json:"url,omitempty"`
+	MiniProgram *TemplateMiniProgram `json:"miniprogram,omitempty"`
+	// Used to avoid duplicated sending.
+	ClientMsgID string `json:"client_msg_id,omitempty"`
+}
+
+type sendTemplateResult struct {
+	wx.ResultBase
+
+	MsgID int64 `json:"msgid"`
+}
+
+// Send a template message, return its msgid, which is reported later in the
+// TEMPLATESENDJOBFINISH event. The message is tracked by TemplateTracker if set.
+func (mp *MP) SendTemplateMessage(ctx context.Context, msg *TemplateMessage, l wx.Logger) (int64, error) {
+
+	if msg.ToUser == "" || msg.TemplateID == "" {
+
+		return 0, fmt.Errorf("SendTemplateMessage: touser/template_id are required")
+
+	}
+
+	if msg.MiniProgram != nil && msg.MiniProgram.AppID == "" {
+
+		return 0, fmt.Errorf("SendTemplateMessage: miniprogram's appid is required")
+
+	}
+
+	r := &sendTemplateResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/message/template/send",
+		msg, r, l); err != nil {
+
+		return 0, err
+
+	}
+
+	if mp.TemplateTracker != nil {
+
+		mp.TemplateTracker.Track(r.MsgID, msg)
+
+	}
+
+	return r.MsgID, nil
+
+}
+
+// A private template of the account.
+type Template struct {
+	TemplateID      string `json:"template_id"`
+	Title           string `json:"title"`
+	PrimaryIndustry string `json:"primary_industry"`
+	DeputyIndustry  string `json:"deputy_industry"`
+	Content         string `json:"content"`
+	Example         string `json:"example"`
+}
+
+type getAllPrivateTemplateResult struct {
+	wx.ResultBase
+
+	TemplateList []Template `json:"template_list"`
+}
+
+// List private templates of the account.
+func (mp *MP) GetAllPrivateTemplate(ctx context.Context, l wx.Logger) ([]Template, error) {
+
+	r := &getAllPrivateTemplateResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/template/get_all_private_template",
+		nil, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r.TemplateList, nil
+
+}
+
+type addTemplateResult struct {
+	wx.ResultBase
+
+	TemplateID string `json:"template_id"`
+}
+
+// Add a template from the template library into private templates, return its
+// template_id. keyword_name_list is optional.
+func (mp *MP) AddTemplate(ctx context.Context, template_id_short string, keyword_name_list []string,
+	l wx.Logger) (string, error) {
+
+	r := &addTemplateResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/template/api_add_template",
+		map[string]interface{}{
+			"template_id_short": template_id_short,
+			"keyword_name_list": keyword_name_list,
+		}, r, l); err != nil {
+
+		return "", err
+
+	}
+
+	return r.TemplateID, nil
+
+}
+
+// Delete a private template.
+func (mp *MP) DelPrivateTemplate(ctx context.Context, template_id string, l wx.Logger) error {
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/template/del_private_template",
+		map[string]string{
+			"template_id": template_id,
+		}, nil, l)
+
+}
+
+// Set the account's industries (by industry codes).
+func (mp *MP) SetIndustry(ctx context.Context, industry_id1, industry_id2 string, l wx.Logger) error {
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/template/api_set_industry",
+		map[string]string{
+			"industry_id1": industry_id1,
+			"industry_id2": industry_id2,
+		}, nil, l)
+
+}
+
+type Industry struct {
+	FirstClass  string `json:"first_class"`
+	SecondClass string `json:"second_class"`
+}
+
+type IndustryResult struct {
+	PrimaryIndustry   Industry `json:"primary_industry"`
+	SecondaryIndustry Industry `json:"secondary_industry"`
+}
+
+// Get the account's industries.
+func (mp *MP) GetIndustry(ctx context.Context, l wx.Logger) (*IndustryResult, error) {
+
+	r := &IndustryResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/template/get_industry",
+		nil, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r, nil
+
+}
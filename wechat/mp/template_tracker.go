@@ -0,0 +1,153 @@
+package mp
+
+import (
+	"github.com/huangjunwen/WechatDriver/wechat/mp/server"
+	"sync"
+)
+
+// Status of a template message reported by TEMPLATESENDJOBFINISH.
+type TemplateSendStatus string
+
+const (
+	// Not yet reported.
+	TEMPLATE_SEND_PENDING       TemplateSendStatus = ""
+	TEMPLATE_SEND_SUCCESS       TemplateSendStatus = "success"
+	TEMPLATE_SEND_USER_BLOCK    TemplateSendStatus = "failed:user block"
+	TEMPLATE_SEND_SYSTEM_FAILED TemplateSendStatus = "failed: system failed"
+)
+
+// A tracked template message.
+type TemplateSendRecord struct {
+	MsgID   int64
+	Message *TemplateMessage
+	Status  TemplateSendStatus
+}
+
+// TemplateTracker correlates msgid of sent template messages with their
+// TEMPLATESENDJOBFINISH events. Register it in the message server's router:
+//
+//	router.HandleEvent(server.EVENT_TEMPLATESENDJOBFINISH, tracker)
+//
+// Records are kept in memory until reported, or until MaxPending is exceeded
+// (the oldest ones are dropped). The zero value is ready to use.
+type TemplateTracker struct {
+	// Called when a tracked message's status is reported, can be nil.
+	OnFinish func(record *TemplateSendRecord)
+
+	// Max number of pending records. Default to 10000.
+	MaxPending int
+
+	mu      sync.Mutex
+	pending map[int64]*TemplateSendRecord
+	order   []int64
+}
+
+// Create an empty TemplateTracker.
+func NewTemplateTracker(on_finish func(record *TemplateSendRecord)) *TemplateTracker {
+
+	return &TemplateTracker{
+		OnFinish: on_finish,
+	}
+
+}
+
+// Track a sent message.
+func (t *TemplateTracker) Track(msgid int64, msg *TemplateMessage) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	max_pending := t.MaxPending
+
+	if max_pending <= 0 {
+
+		max_pending = 10000
+
+	}
+
+	for len(t.order) >= max_pending {
+
+		delete(t.pending, t.order[0])
+
+		t.order = t.order[1:]
+
+	}
+
+	if t.pending == nil {
+
+		t.pending = make(map[int64]*TemplateSendRecord)
+
+	}
+
+	t.pending[msgid] = &TemplateSendRecord{
+		MsgID:   msgid,
+		Message: msg,
+	}
+
+	t.order = append(t.order, msgid)
+
+}
+
+// Return the pending record of msgid, nil if not tracked or already reported.
+func (t *TemplateTracker) Pending(msgid int64) *TemplateSendRecord {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.pending[msgid]
+
+}
+
+// Implement server.Handler for TEMPLATESENDJOBFINISH events.
+func (t *TemplateTracker) ServeMessage(c *server.Context) error {
+
+	ev, ok := c.Message.(*server.TemplateSendJobFinishEvent)
+
+	if !ok {
+
+		return nil
+
+	}
+
+	t.mu.Lock()
+
+	record, ok := t.pending[ev.MsgID]
+
+	if ok {
+
+		delete(t.pending, ev.MsgID)
+
+		for i, msgid := range t.order {
+
+			if msgid == ev.MsgID {
+
+				t.order = append(t.order[:i], t.order[i+1:]...)
+
+				break
+
+			}
+
+		}
+
+	} else {
+
+		// Not tracked (e.g. sent by another process), report it anyway.
+		record = &TemplateSendRecord{
+			MsgID: ev.MsgID,
+		}
+
+	}
+
+	record.Status = TemplateSendStatus(ev.Status)
+
+	t.mu.Unlock()
+
+	if t.OnFinish != nil {
+
+		t.OnFinish(record)
+
+	}
+
+	return nil
+
+}
@@ -0,0 +1,187 @@
+package mp
+
+import (
+	"context"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/internal/wxtest"
+	"github.com/huangjunwen/WechatDriver/wechat/mp/server"
+	"github.com/huangjunwen/WechatDriver/wechat/token"
+	"net/http"
+	"testing"
+)
+
+// Create a MP talking to a fake server, which issues access token "T" and passes
+// other requests to h.
+func newTestMP(t *testing.T, h http.HandlerFunc) *MP {
+
+	client := wxtest.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Path == "/cgi-bin/token" {
+
+			fmt.Fprint(w, `{"access_token":"T","expires_in":7200}`)
+
+			return
+
+		}
+
+		h(w, r)
+
+	}))
+
+	tokens, err := token.NewAccessTokenManager(&wx.AppConfig{AppID: "app", AppSecret: "secret"}, client)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	mp, err := NewMP(tokens)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	return mp
+
+}
+
+func templateSendJobFinish(msgid int64, status string) *server.Context {
+
+	return &server.Context{
+		Context: context.Background(),
+		Message: &server.TemplateSendJobFinishEvent{
+			MessageHeader: server.MessageHeader{
+				MsgType: server.MSG_TYPE_EVENT,
+				Event:   server.EVENT_TEMPLATESENDJOBFINISH,
+			},
+			MsgID:  msgid,
+			Status: status,
+		},
+	}
+
+}
+
+func TestTemplateTracker(t *testing.T) {
+
+	var next_msgid int64 = 100
+
+	mp := newTestMP(t, func(w http.ResponseWriter, r *http.Request) {
+
+		next_msgid++
+
+		fmt.Fprintf(w, `{"errcode":0,"errmsg":"ok","msgid":%d}`, next_msgid)
+
+	})
+
+	var finished []*TemplateSendRecord
+
+	// The zero value is usable.
+	mp.TemplateTracker = &TemplateTracker{
+		OnFinish: func(record *TemplateSendRecord) {
+			finished = append(finished, record)
+		},
+	}
+
+	msg1 := &TemplateMessage{ToUser: "u1", TemplateID: "t"}
+
+	msgid1, err := mp.SendTemplateMessage(context.Background(), msg1, nil)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	msg2 := &TemplateMessage{ToUser: "u2", TemplateID: "t"}
+
+	msgid2, err := mp.SendTemplateMessage(context.Background(), msg2, nil)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if msgid1 != 101 || msgid2 != 102 {
+
+		t.Fatalf("unexpected msgid %d %d", msgid1, msgid2)
+
+	}
+
+	if record := mp.TemplateTracker.Pending(msgid1); record == nil || record.Message != msg1 {
+
+		t.Fatalf("expect %d pending, got %+v", msgid1, record)
+
+	}
+
+	// Reported out of order, and an unknown one.
+	for _, c := range []*server.Context{
+		templateSendJobFinish(msgid2, "failed:user block"),
+		templateSendJobFinish(999, "success"),
+		templateSendJobFinish(msgid1, "success"),
+	} {
+
+		if err := mp.TemplateTracker.ServeMessage(c); err != nil {
+
+			t.Fatal(err)
+
+		}
+
+	}
+
+	if len(finished) != 3 {
+
+		t.Fatalf("expect 3 records, got %d", len(finished))
+
+	}
+
+	for i, expect := range []TemplateSendRecord{
+		{MsgID: msgid2, Message: msg2, Status: TEMPLATE_SEND_USER_BLOCK},
+		{MsgID: 999, Message: nil, Status: TEMPLATE_SEND_SUCCESS},
+		{MsgID: msgid1, Message: msg1, Status: TEMPLATE_SEND_SUCCESS},
+	} {
+
+		if *finished[i] != expect {
+
+			t.Errorf("record %d: expect %+v, got %+v", i, expect, *finished[i])
+
+		}
+
+	}
+
+	if mp.TemplateTracker.Pending(msgid1) != nil || mp.TemplateTracker.Pending(msgid2) != nil {
+
+		t.Fatal("reported records should not be pending")
+
+	}
+
+}
+
+func TestTemplateTrackerMaxPending(t *testing.T) {
+
+	tracker := &TemplateTracker{MaxPending: 2}
+
+	// Events before any Track on a zero value.
+	if err := tracker.ServeMessage(templateSendJobFinish(1, "success")); err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	for msgid := int64(1); msgid <= 3; msgid++ {
+
+		tracker.Track(msgid, &TemplateMessage{})
+
+	}
+
+	if tracker.Pending(1) != nil || tracker.Pending(2) == nil || tracker.Pending(3) == nil {
+
+		t.Fatal("expect the oldest record dropped")
+
+	}
+
+}
@@ -0,0 +1,184 @@
+package mp
+
+import (
+	"context"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/oauth2"
+	"net/url"
+	"unicode/utf8"
+)
+
+// Limits of user APIs.
+const (
+	MaxBatchGetUserInfo = 100
+	MaxRemarkChars      = 30
+)
+
+// Info of a follower.
+type UserInfo struct {
+	// 0 if the user has not subscribed, other fields are absent then.
+	Subscribe     int             `json:"subscribe"`
+	OpenID        string          `json:"openid"`
+	Language      oauth2.Language `json:"language"`
+	SubscribeTime int64           `json:"subscribe_time"`
+	UnionID       string          `json:"unionid"`
+	Remark        string          `json:"remark"`
+	GroupID       int             `json:"groupid"`
+	TagIDList     []int           `json:"tagid_list"`
+	// e.g. "ADD_SCENE_QR_CODE", "ADD_SCENE_SEARCH"
+	SubscribeScene string `json:"subscribe_scene"`
+	QRScene        int64  `json:"qr_scene"`
+	QRSceneStr     string `json:"qr_scene_str"`
+}
+
+// Is the user following the account ?
+func (u *UserInfo) Subscribed() bool {
+	return u.Subscribe == 1
+}
+
+type userInfoResult struct {
+	wx.ResultBase
+	UserInfo
+}
+
+// Get info of a user. lang is optional (default to LANG_ZH_CN).
+func (mp *MP) GetUserInfo(ctx context.Context, openid string, lang oauth2.Language, l wx.Logger) (*UserInfo, error) {
+
+	if lang == "" {
+
+		lang = oauth2.LANG_ZH_CN
+
+	}
+
+	if !lang.Valid() {
+
+		return nil, fmt.Errorf("GetUserInfo: invalid lang %+q", lang)
+
+	}
+
+	r := &userInfoResult{}
+
+	if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/user/info?"+url.Values{
+		"openid": []string{openid},
+		"lang":   []string{string(lang)},
+	}.Encode(), nil, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return &r.UserInfo, nil
+
+}
+
+type batchGetUser struct {
+	OpenID string          `json:"openid"`
+	Lang   oauth2.Language `json:"lang"`
+}
+
+type batchGetUserInfoResult struct {
+	wx.ResultBase
+
+	UserInfoList []UserInfo `json:"user_info_list"`
+}
+
+// Get info of users, openids are sent in batches of 100. lang is optional (default
+// to LANG_ZH_CN).
+func (mp *MP) BatchGetUserInfo(ctx context.Context, openids []string, lang oauth2.Language,
+	l wx.Logger) ([]UserInfo, error) {
+
+	if lang == "" {
+
+		lang = oauth2.LANG_ZH_CN
+
+	}
+
+	if !lang.Valid() {
+
+		return nil, fmt.Errorf("BatchGetUserInfo: invalid lang %+q", lang)
+
+	}
+
+	ret := make([]UserInfo, 0, len(openids))
+
+	for len(openids) > 0 {
+
+		n := len(openids)
+
+		if n > MaxBatchGetUserInfo {
+
+			n = MaxBatchGetUserInfo
+
+		}
+
+		users := make([]batchGetUser, 0, n)
+
+		for _, openid := range openids[:n] {
+
+			users = append(users, batchGetUser{
+				OpenID: openid,
+				Lang:   lang,
+			})
+
+		}
+
+		r := &batchGetUserInfoResult{}
+
+		if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/user/info/batchget",
+			map[string]interface{}{
+				"user_list": users,
+			}, r, l); err != nil {
+
+			return nil, err
+
+		}
+
+		ret = append(ret, r.UserInfoList...)
+
+		openids = openids[n:]
+
+	}
+
+	return ret, nil
+
+}
+
+// Iterate followers starting after next_openid (empty to start from the beginning).
+func (mp *MP) Followers(ctx context.Context, next_openid string, l wx.Logger) *OpenIDIterator {
+
+	return newOpenIDIterator(ctx, next_openid, l, func(ctx context.Context, next_openid string,
+		l wx.Logger) (*openIDPage, error) {
+
+		r := &openIDPage{}
+
+		if err := mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/user/get?"+url.Values{
+			"next_openid": []string{next_openid},
+		}.Encode(), nil, r, l); err != nil {
+
+			return nil, err
+
+		}
+
+		return r, nil
+
+	})
+
+}
+
+// Set remark name of a user.
+func (mp *MP) UpdateRemark(ctx context.Context, openid, remark string, l wx.Logger) error {
+
+	if utf8.RuneCountInString(remark) > MaxRemarkChars {
+
+		return fmt.Errorf("UpdateRemark: remark should be at most %d chars", MaxRemarkChars)
+
+	}
+
+	return mp.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/user/info/updateremark",
+		map[string]string{
+			"openid": openid,
+			"remark": remark,
+		}, nil, l)
+
+}
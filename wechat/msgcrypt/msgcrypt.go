@@ -0,0 +1,329 @@
+// Package msgcrypt is a Go implementation of Wechat's WXBizMsgCrypt, which
+// encrypts/decrypts messages of official accounts in compatible/safe mode.
+//
+// The algorithm (see Wechat's reference implementation):
+//
+//	aes_key = base64_decode(EncodingAESKey + "=")  // 32 bytes
+//	iv = aes_key[:16]
+//	plain = random(16) + uint32_be(len(msg)) + msg + appid
+//	encrypt = base64(AES-256-CBC(aes_key, iv, PKCS7(plain, 32)))
+//	msg_signature = sha1(sort(token, timestamp, nonce, encrypt) joined)
+package msgcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Block size used in PKCS7 padding, NOTE: it's 32 rather than AES's block size.
+const blockSize = 32
+
+var (
+	ErrInvalidSignature = errors.New("msgcrypt: invalid msg_signature")
+	ErrInvalidAppID     = errors.New("msgcrypt: appid mismatch")
+	ErrInvalidData      = errors.New("msgcrypt: invalid encrypted data")
+)
+
+// MsgCrypt encrypts/decrypts messages of an app.
+type MsgCrypt struct {
+	token  string
+	appid  string
+	aesKey []byte
+
+	// Source of the random prefix and nonces. Default to wx.CryptoNonceSource.
+	NonceSource wx.NonceSource
+}
+
+// Create MsgCrypt from the token, EncodingAESKey (43 chars) configured in MP console
+// and the app's appid.
+func NewMsgCrypt(token, encoding_aes_key, appid string) (*MsgCrypt, error) {
+
+	if token == "" || appid == "" {
+
+		return nil, fmt.Errorf("NewMsgCrypt: token/appid missing")
+
+	}
+
+	if len(encoding_aes_key) != 43 {
+
+		return nil, fmt.Errorf("NewMsgCrypt: EncodingAESKey should be 43 chars")
+
+	}
+
+	aes_key, err := base64.StdEncoding.DecodeString(encoding_aes_key + "=")
+
+	if err != nil {
+
+		return nil, fmt.Errorf("NewMsgCrypt: bad EncodingAESKey: %s", err)
+
+	}
+
+	return &MsgCrypt{
+		token:  token,
+		appid:  appid,
+		aesKey: aes_key,
+	}, nil
+
+}
+
+// Return the appid.
+func (mc *MsgCrypt) AppID() string {
+	return mc.appid
+}
+
+// msg_signature of an encrypted message.
+func Signature(token, timestamp, nonce, encrypt string) string {
+
+	strs := sort.StringSlice{token, timestamp, nonce, encrypt}
+
+	strs.Sort()
+
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(strs, ""))))
+
+}
+
+// Verify msg_signature.
+func (mc *MsgCrypt) VerifySignature(msg_signature, timestamp, nonce, encrypt string) bool {
+
+	expect := Signature(mc.token, timestamp, nonce, encrypt)
+
+	return subtle.ConstantTimeCompare([]byte(expect), []byte(msg_signature)) == 1
+
+}
+
+// Encrypt msg into base64 text.
+func (mc *MsgCrypt) Encrypt(msg []byte) (string, error) {
+
+	random, err := wx.NonceStr(mc.NonceSource, 16)
+
+	if err != nil {
+
+		return "", err
+
+	}
+
+	if len(random) != 16 {
+
+		return "", fmt.Errorf("Encrypt: random prefix should be 16 bytes")
+
+	}
+
+	buf := new(bytes.Buffer)
+
+	buf.WriteString(random)
+
+	binary.Write(buf, binary.BigEndian, uint32(len(msg)))
+
+	buf.Write(msg)
+
+	buf.WriteString(mc.appid)
+
+	plain := pkcs7Pad(buf.Bytes())
+
+	block, err := aes.NewCipher(mc.aesKey)
+
+	if err != nil {
+
+		return "", err
+
+	}
+
+	ciphertext := make([]byte, len(plain))
+
+	cipher.NewCBCEncrypter(block, mc.aesKey[:aes.BlockSize]).CryptBlocks(ciphertext, plain)
+
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+
+}
+
+// Decrypt base64 text into msg, the appid embedded is checked.
+func (mc *MsgCrypt) Decrypt(encrypt string) ([]byte, error) {
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypt)
+
+	if err != nil {
+
+		return nil, ErrInvalidData
+
+	}
+
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+
+		return nil, ErrInvalidData
+
+	}
+
+	block, err := aes.NewCipher(mc.aesKey)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	plain := make([]byte, len(ciphertext))
+
+	cipher.NewCBCDecrypter(block, mc.aesKey[:aes.BlockSize]).CryptBlocks(plain, ciphertext)
+
+	if plain, err = pkcs7Unpad(plain); err != nil {
+
+		return nil, err
+
+	}
+
+	if len(plain) < 20 {
+
+		return nil, ErrInvalidData
+
+	}
+
+	msg_len := int(binary.BigEndian.Uint32(plain[16:20]))
+
+	if msg_len < 0 || 20+msg_len > len(plain) {
+
+		return nil, ErrInvalidData
+
+	}
+
+	msg := plain[20 : 20+msg_len]
+
+	if string(plain[20+msg_len:]) != mc.appid {
+
+		return nil, ErrInvalidAppID
+
+	}
+
+	return msg, nil
+
+}
+
+// The encrypted envelope of inbound messages.
+type EncryptedMessage struct {
+	XMLName    xml.Name `xml:"xml"`
+	ToUserName string   `xml:"ToUserName"`
+	Encrypt    string   `xml:"Encrypt"`
+}
+
+// Verify msg_signature and decrypt an inbound message (the request body), the
+// parameters are from the request's query.
+func (mc *MsgCrypt) DecryptMessage(body []byte, msg_signature, timestamp, nonce string) ([]byte, error) {
+
+	env := &EncryptedMessage{}
+
+	if err := xml.Unmarshal(body, env); err != nil {
+
+		return nil, err
+
+	}
+
+	if env.Encrypt == "" {
+
+		return nil, ErrInvalidData
+
+	}
+
+	if !mc.VerifySignature(msg_signature, timestamp, nonce, env.Encrypt) {
+
+		return nil, ErrInvalidSignature
+
+	}
+
+	return mc.Decrypt(env.Encrypt)
+
+}
+
+type cdata struct {
+	Text string `xml:",cdata"`
+}
+
+// The encrypted envelope of replies.
+type EncryptedReply struct {
+	XMLName      xml.Name `xml:"xml"`
+	Encrypt      cdata    `xml:"Encrypt"`
+	MsgSignature cdata    `xml:"MsgSignature"`
+	TimeStamp    string   `xml:"TimeStamp"`
+	Nonce        cdata    `xml:"Nonce"`
+}
+
+// Encrypt a reply (plain XML) into the encrypted envelope. If nonce is empty, a
+// random one is generated.
+func (mc *MsgCrypt) EncryptReply(reply []byte, timestamp int64, nonce string) ([]byte, error) {
+
+	encrypt, err := mc.Encrypt(reply)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	if nonce == "" {
+
+		if nonce, err = wx.NonceStr(mc.NonceSource, 10); err != nil {
+
+			return nil, err
+
+		}
+
+	}
+
+	ts := strconv.FormatInt(timestamp, 10)
+
+	return xml.Marshal(&EncryptedReply{
+		Encrypt:      cdata{encrypt},
+		MsgSignature: cdata{Signature(mc.token, ts, nonce, encrypt)},
+		TimeStamp:    ts,
+		Nonce:        cdata{nonce},
+	})
+
+}
+
+func pkcs7Pad(data []byte) []byte {
+
+	pad := blockSize - len(data)%blockSize
+
+	return append(data, bytes.Repeat([]byte{byte(pad)}, pad)...)
+
+}
+
+func pkcs7Unpad(data []byte) ([]byte, error) {
+
+	if len(data) == 0 {
+
+		return nil, ErrInvalidData
+
+	}
+
+	pad := int(data[len(data)-1])
+
+	if pad < 1 || pad > blockSize || pad > len(data) {
+
+		return nil, ErrInvalidData
+
+	}
+
+	for _, b := range data[len(data)-pad:] {
+
+		if int(b) != pad {
+
+			return nil, ErrInvalidData
+
+		}
+
+	}
+
+	return data[:len(data)-pad], nil
+
+}
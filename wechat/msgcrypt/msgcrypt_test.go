@@ -0,0 +1,341 @@
+package msgcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"testing"
+)
+
+// Vectors from Wechat's reference WXBizMsgCrypt sample (Sample.py).
+const (
+	refToken          = "spamtest"
+	refEncodingAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+	refAppID          = "wx2c2769f8efd9abc2"
+	refTimestamp      = "1409735669"
+	refNonce          = "1320562132"
+	refMsgSignature   = "5d197aaffba7e9b25a30732f161a50dee96bd5fa"
+
+	refEncrypt = "hyzAe4OzmOMbd6TvGdIOO6uBmdJoD0Fk53REIHvxYtJlE2B655HuD0m8KUePWB3+LrPXo87wzQ1QLvbeUgmBM4x6F8PGHQHFVAFmOD2LdJF9FrXpbUAh0B5GIItb52sn896wVsMSHGuPE328HnRGBcrS7C41IzDWyWNlZkyyXwon8T332jisa+h6tEDYsVticbSnyU8dKOIbgU6ux5VTjg3yt+WGzjlpKn6NPhRjpA912xMezR4kw6KWwMrCVKSVCZciVGCgavjIQ6X8tCOp3yZbGpy0VxpAe+77TszTfRd5RJSVO/HTnifJpXgCSUdUue1v6h0EIBYYI1BD1DlD+C0CR8e6OewpusjZ4uBl9FyJvnhvQl+q5rv1ixrcpCumEPo5MJSgM9ehVsNPfUM669WuMyVWQLCzpu9GhglF2PE="
+
+	refPlain = "<xml><ToUserName><![CDATA[gh_10f6c3c3ac5a]]></ToUserName>\n" +
+		"<FromUserName><![CDATA[oyORnuP8q7ou2gfYjqLzSIWZf0rs]]></FromUserName>\n" +
+		"<CreateTime>1409735668</CreateTime>\n" +
+		"<MsgType><![CDATA[text]]></MsgType>\n" +
+		"<Content><![CDATA[abcdteT]]></Content>\n" +
+		"<MsgId>6054768590064713728</MsgId>\n" +
+		"</xml>"
+
+	// The random prefix embedded in refEncrypt.
+	refRandom = "89465c840c5f116f"
+)
+
+func newRefMsgCrypt(t *testing.T, appid string) *MsgCrypt {
+
+	mc, err := NewMsgCrypt(refToken, refEncodingAESKey, appid)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	return mc
+
+}
+
+// Encrypt raw plain text (no prefix/length/appid, no padding added).
+func rawEncrypt(t *testing.T, mc *MsgCrypt, plain []byte) string {
+
+	block, err := aes.NewCipher(mc.aesKey)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	ciphertext := make([]byte, len(plain))
+
+	cipher.NewCBCEncrypter(block, mc.aesKey[:aes.BlockSize]).CryptBlocks(ciphertext, plain)
+
+	return base64.StdEncoding.EncodeToString(ciphertext)
+
+}
+
+// random + len + msg + appid, without padding.
+func rawPlain(msg, appid string) []byte {
+
+	buf := new(bytes.Buffer)
+
+	buf.WriteString(refRandom)
+
+	binary.Write(buf, binary.BigEndian, uint32(len(msg)))
+
+	buf.WriteString(msg)
+
+	buf.WriteString(appid)
+
+	return buf.Bytes()
+
+}
+
+func TestSignatureReference(t *testing.T) {
+
+	if s := Signature(refToken, refTimestamp, refNonce, refEncrypt); s != refMsgSignature {
+
+		t.Fatalf("Signature: expect %s but got %s", refMsgSignature, s)
+
+	}
+
+	mc := newRefMsgCrypt(t, refAppID)
+
+	if !mc.VerifySignature(refMsgSignature, refTimestamp, refNonce, refEncrypt) {
+
+		t.Fatal("VerifySignature: expect true")
+
+	}
+
+	if mc.VerifySignature(refMsgSignature, "1409735670", refNonce, refEncrypt) {
+
+		t.Fatal("VerifySignature: expect false for another timestamp")
+
+	}
+
+}
+
+func TestDecryptReference(t *testing.T) {
+
+	mc := newRefMsgCrypt(t, refAppID)
+
+	msg, err := mc.Decrypt(refEncrypt)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if string(msg) != refPlain {
+
+		t.Fatalf("Decrypt: unexpected %q", msg)
+
+	}
+
+}
+
+func TestEncryptReference(t *testing.T) {
+
+	mc := newRefMsgCrypt(t, refAppID)
+
+	mc.NonceSource = wx.NonceSourceFunc(func(n int) (string, error) {
+		return refRandom[:n], nil
+	})
+
+	encrypt, err := mc.Encrypt([]byte(refPlain))
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if encrypt != refEncrypt {
+
+		t.Fatalf("Encrypt: unexpected %s", encrypt)
+
+	}
+
+}
+
+func TestDecryptMessageReference(t *testing.T) {
+
+	mc := newRefMsgCrypt(t, refAppID)
+
+	body := []byte("<xml><ToUserName><![CDATA[gh_10f6c3c3ac5a]]></ToUserName><Encrypt><![CDATA[" +
+		refEncrypt + "]]></Encrypt></xml>")
+
+	msg, err := mc.DecryptMessage(body, refMsgSignature, refTimestamp, refNonce)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if string(msg) != refPlain {
+
+		t.Fatalf("DecryptMessage: unexpected %q", msg)
+
+	}
+
+	if _, err := mc.DecryptMessage(body, refMsgSignature, refTimestamp, "1320562133"); err != ErrInvalidSignature {
+
+		t.Fatalf("DecryptMessage: expect ErrInvalidSignature but got %v", err)
+
+	}
+
+}
+
+func TestEncryptReply(t *testing.T) {
+
+	mc := newRefMsgCrypt(t, refAppID)
+
+	reply := []byte("<xml><Content><![CDATA[hi]]></Content></xml>")
+
+	data, err := mc.EncryptReply(reply, 1409735669, refNonce)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	env := &struct {
+		Encrypt      string `xml:"Encrypt"`
+		MsgSignature string `xml:"MsgSignature"`
+		TimeStamp    string `xml:"TimeStamp"`
+		Nonce        string `xml:"Nonce"`
+	}{}
+
+	if err := xml.Unmarshal(data, env); err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if env.TimeStamp != refTimestamp || env.Nonce != refNonce {
+
+		t.Fatalf("EncryptReply: unexpected envelope %s", data)
+
+	}
+
+	if !mc.VerifySignature(env.MsgSignature, env.TimeStamp, env.Nonce, env.Encrypt) {
+
+		t.Fatal("EncryptReply: bad msg_signature")
+
+	}
+
+	msg, err := mc.Decrypt(env.Encrypt)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if !bytes.Equal(msg, reply) {
+
+		t.Fatalf("EncryptReply: unexpected %q", msg)
+
+	}
+
+}
+
+func TestDecryptWrongAppID(t *testing.T) {
+
+	mc := newRefMsgCrypt(t, "wx0000000000000000")
+
+	if _, err := mc.Decrypt(refEncrypt); err != ErrInvalidAppID {
+
+		t.Fatalf("Decrypt: expect ErrInvalidAppID but got %v", err)
+
+	}
+
+}
+
+func TestDecryptPadding(t *testing.T) {
+
+	mc := newRefMsgCrypt(t, refAppID)
+
+	// 16 + 4 + 2 + 18 = 40 bytes, PKCS7 with 32-byte block pads 24 bytes (more
+	// than AES's block size), which must be accepted.
+	plain := rawPlain("hi", refAppID)
+
+	if len(plain) != 40 {
+
+		t.Fatalf("unexpected plain length %d", len(plain))
+
+	}
+
+	pad := func(p []byte, pad_byte byte, n int) []byte {
+
+		return append(append([]byte{}, p...), bytes.Repeat([]byte{pad_byte}, n)...)
+
+	}
+
+	msg, err := mc.Decrypt(rawEncrypt(t, mc, pad(plain, 24, 24)))
+
+	if err != nil || string(msg) != "hi" {
+
+		t.Fatalf("Decrypt: expect %q but got %q %v", "hi", msg, err)
+
+	}
+
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"zero pad", pad(plain, 0, 24)},
+		{"pad exceeds 32-byte block", pad(pad(plain, 0, 24), 33, 32)},
+		{"inconsistent pad bytes", append(pad(plain, 23, 23), 24)},
+	} {
+
+		if _, err := mc.Decrypt(rawEncrypt(t, mc, c.data)); err != ErrInvalidData {
+
+			t.Errorf("Decrypt (%s): expect ErrInvalidData but got %v", c.name, err)
+
+		}
+
+	}
+
+}
+
+func TestDecryptTruncated(t *testing.T) {
+
+	mc := newRefMsgCrypt(t, refAppID)
+
+	ciphertext, _ := base64.StdEncoding.DecodeString(refEncrypt)
+
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not block aligned", ciphertext[:len(ciphertext)-5]},
+		{"last block dropped", ciphertext[:len(ciphertext)-aes.BlockSize]},
+		{"single block", ciphertext[:aes.BlockSize]},
+	} {
+
+		_, err := mc.Decrypt(base64.StdEncoding.EncodeToString(c.data))
+
+		if err != ErrInvalidData {
+
+			t.Errorf("Decrypt (%s): expect ErrInvalidData but got %v", c.name, err)
+
+		}
+
+	}
+
+	if _, err := mc.Decrypt("not base64!"); err != ErrInvalidData {
+
+		t.Errorf("Decrypt (bad base64): expect ErrInvalidData but got %v", err)
+
+	}
+
+	// Declared length exceeds the data.
+	plain := rawPlain("hi", refAppID)
+
+	binary.BigEndian.PutUint32(plain[16:20], 1000)
+
+	if _, err := mc.Decrypt(rawEncrypt(t, mc, pkcs7Pad(plain))); err != ErrInvalidData {
+
+		t.Errorf("Decrypt (bad length): expect ErrInvalidData but got %v", err)
+
+	}
+
+}
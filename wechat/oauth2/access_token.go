@@ -28,6 +28,8 @@ func (r *AccessTokenResult) Scopes() (ret []OAuth2Scope) {
 			ret = append(ret, OAUTH2_SCOPE_BASE)
 		case string(OAUTH2_SCOPE_USERINFO):
 			ret = append(ret, OAUTH2_SCOPE_USERINFO)
+		case string(OAUTH2_SCOPE_LOGIN):
+			ret = append(ret, OAUTH2_SCOPE_LOGIN)
 		default:
 		}
 	}
@@ -45,7 +47,8 @@ func (r *AccessTokenResult) HasScope(scope OAuth2Scope) bool {
 }
 
 // Get access token (and refresh token) from code. Return error only when there
-// is error in transport. Caller should also check r.Error().
+// is error in transport (or errcode != 0 when ReturnAPIErrors is set). Caller
+// should also check r.Error().
 func (o *OAuth2) AccessToken(ctx context.Context, code string, l wx.Logger) (
 	*AccessTokenResult, error) {
 
@@ -66,7 +69,8 @@ func (o *OAuth2) AccessToken(ctx context.Context, code string, l wx.Logger) (
 }
 
 // Refresh access token (and refresh token) from refresh token. Return error only when there
-// is error in transport. Caller should also check r.Error().
+// is error in transport (or errcode != 0 when ReturnAPIErrors is set). Caller
+// should also check r.Error().
 func (o *OAuth2) RefreshAccessToken(ctx context.Context, refresh_token string, l wx.Logger) (
 	*AccessTokenResult, error) {
 
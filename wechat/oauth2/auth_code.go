@@ -36,7 +36,9 @@ func (o *OAuth2) AuthCodeURL(scope OAuth2Scope, callback_url string, csrf string
 
 }
 
-// Generating a URL to start Oauth2 process using QRcode in PC browser.
+// Generating a URL to start Oauth2 process using QRcode in PC browser. The
+// OAuth2 instance should be created with an open platform website app config, see
+// AppConfig.WebsiteAppConfig.
 //
 //   param callback_url: the url to jump back after authorization
 //   param csrf: CSRF token
@@ -47,8 +49,100 @@ func (o *OAuth2) AuthCodeURLQR(callback_url string, csrf string) string {
 		"?appid=%s&redirect_uri=%s&response_type=code&scope=%s&state=%s#wechat_redirect",
 		url.QueryEscape(o.config.AppID),
 		url.QueryEscape(callback_url),
-		"snsapi_login",
+		string(OAUTH2_SCOPE_LOGIN),
 		url.QueryEscape(csrf),
 	)
 
 }
+
+// Options of the embedded QR login widget (wxLogin.js).
+type QRWidgetOptions struct {
+	// If true, jump to callback_url inside the iframe, otherwise in the top window.
+	SelfRedirect bool
+
+	// "black" or "white" (default).
+	Style string
+
+	// URL of a custom CSS (must be https) to style the widget.
+	Href string
+}
+
+// Parameters of `new WxLogin({...})` in wxLogin.js, marshal it into JSON and
+// pass it to the widget.
+type QRWidgetConfig struct {
+	SelfRedirect bool   `json:"self_redirect"`
+	ID           string `json:"id"`
+	AppID        string `json:"appid"`
+	Scope        string `json:"scope"`
+	RedirectURI  string `json:"redirect_uri"`
+	State        string `json:"state"`
+	Style        string `json:"style,omitempty"`
+	Href         string `json:"href,omitempty"`
+}
+
+// Generating parameters for the embedded QR login widget.
+//
+//   param id: id of the container element of the widget
+//   param callback_url: the url to jump back after authorization
+//   param csrf: CSRF token
+//   param opts: widget options, can be nil
+func (o *OAuth2) QRWidgetConfig(id, callback_url, csrf string, opts *QRWidgetOptions) *QRWidgetConfig {
+
+	if opts == nil {
+
+		opts = &QRWidgetOptions{}
+
+	}
+
+	return &QRWidgetConfig{
+		SelfRedirect: opts.SelfRedirect,
+		ID:           id,
+		AppID:        o.config.AppID,
+		Scope:        string(OAUTH2_SCOPE_LOGIN),
+		// wxLogin.js does not escape it.
+		RedirectURI: url.QueryEscape(callback_url),
+		State:       csrf,
+		Style:       opts.Style,
+		Href:        opts.Href,
+	}
+
+}
+
+// Generating the iframe URL the embedded QR login widget uses. Useful when not
+// using wxLogin.js.
+//
+//   param callback_url: the url to jump back after authorization
+//   param csrf: CSRF token
+//   param opts: widget options, can be nil
+func (o *OAuth2) AuthCodeURLQRWidget(callback_url string, csrf string, opts *QRWidgetOptions) string {
+
+	if opts == nil {
+
+		opts = &QRWidgetOptions{}
+
+	}
+
+	q := url.Values{
+		"appid":         []string{o.config.AppID},
+		"scope":         []string{string(OAUTH2_SCOPE_LOGIN)},
+		"redirect_uri":  []string{callback_url},
+		"state":         []string{csrf},
+		"login_type":    []string{"jssdk"},
+		"self_redirect": []string{fmt.Sprint(opts.SelfRedirect)},
+	}
+
+	if opts.Style != "" {
+
+		q.Set("style", opts.Style)
+
+	}
+
+	if opts.Href != "" {
+
+		q.Set("href", opts.Href)
+
+	}
+
+	return "https://open.weixin.qq.com/connect/qrconnect?" + q.Encode()
+
+}
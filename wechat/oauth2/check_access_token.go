@@ -0,0 +1,108 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"net/url"
+)
+
+// Validity of an access token reported by sns/auth.
+type TokenValidity int
+
+const (
+	TOKEN_VALID TokenValidity = iota
+	TOKEN_INVALID
+	TOKEN_EXPIRED
+	// The check failed for other reasons (e.g. rate limited), see r.Error().
+	TOKEN_UNKNOWN
+)
+
+func (v TokenValidity) String() string {
+
+	switch v {
+
+	case TOKEN_VALID:
+
+		return "valid"
+
+	case TOKEN_EXPIRED:
+
+		return "expired"
+
+	case TOKEN_UNKNOWN:
+
+		return "unknown"
+
+	default:
+
+		return "invalid"
+
+	}
+
+}
+
+// The result of OAuth2.CheckAccessToken
+type CheckAccessTokenResult struct {
+	OAuth2ResultBase
+}
+
+// Validity of the access token. Only access token errcodes (40001/40014/42001)
+// tell the token is unusable, other errcodes are reported as TOKEN_UNKNOWN.
+func (r *CheckAccessTokenResult) Validity() TokenValidity {
+
+	switch r.ErrCode {
+
+	case 0:
+
+		return TOKEN_VALID
+
+	case ERRCODE_ACCESS_TOKEN_EXPIRED:
+
+		return TOKEN_EXPIRED
+
+	case ERRCODE_INVALID_CREDENTIAL, ERRCODE_INVALID_ACCESS_TOKEN:
+
+		return TOKEN_INVALID
+
+	default:
+
+		return TOKEN_UNKNOWN
+
+	}
+
+}
+
+// Is the access token valid ?
+func (r *CheckAccessTokenResult) Valid() bool {
+
+	return r.Validity() == TOKEN_VALID
+
+}
+
+// Check whether an access token (for openid) is still valid using sns/auth. Return
+// error only when there is error in transport (regardless of ReturnAPIErrors).
+// Caller should check r.Validity().
+func (o *OAuth2) CheckAccessToken(ctx context.Context, access_token, openid string, l wx.Logger) (
+	*CheckAccessTokenResult, error) {
+
+	r := &CheckAccessTokenResult{}
+
+	err := o.callOAuth2API(ctx,
+		"https://api.weixin.qq.com/sns/auth?"+url.Values{
+			"access_token": []string{access_token},
+			"openid":       []string{openid},
+		}.Encode(), r, l)
+
+	// An errcode is the answer rather than a failure here, even when ReturnAPIErrors is set.
+	var api_err *APIError
+
+	if err != nil && !errors.As(err, &api_err) {
+
+		return nil, err
+
+	}
+
+	return r, nil
+
+}
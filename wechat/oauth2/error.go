@@ -0,0 +1,62 @@
+package oauth2
+
+import (
+	"fmt"
+)
+
+// Common errcodes of OAuth2 APIs.
+const (
+	ERRCODE_INVALID_CREDENTIAL    = 40001 // access_token invalid or not latest
+	ERRCODE_INVALID_OPENID        = 40003
+	ERRCODE_INVALID_ACCESS_TOKEN  = 40014
+	ERRCODE_INVALID_CODE          = 40029 // invalid or expired code
+	ERRCODE_INVALID_REFRESH_TOKEN = 40030
+	ERRCODE_CODE_BEEN_USED        = 40163
+	ERRCODE_ACCESS_TOKEN_EXPIRED  = 42001
+	ERRCODE_REFRESH_TOKEN_EXPIRED = 42002
+	ERRCODE_API_FREQ_OUT_OF_LIMIT = 45011
+	ERRCODE_API_UNAUTHORIZED      = 48001 // e.g. scope not authorized
+)
+
+// APIError is a failed OAuth2 API result (errcode != 0). Use errors.As to
+// extract it.
+type APIError struct {
+	ErrCode int
+	ErrMsg  string
+}
+
+func (e *APIError) Error() string {
+
+	return fmt.Sprintf("errcode=%v errmsg=%+q", e.ErrCode, e.ErrMsg)
+
+}
+
+// The code is invalid, expired or has been used, the user should authorize again
+// to get a new one.
+func (e *APIError) IsCodeError() bool {
+
+	return e.ErrCode == ERRCODE_INVALID_CODE || e.ErrCode == ERRCODE_CODE_BEEN_USED
+
+}
+
+// The access token is invalid or expired, refresh it.
+func (e *APIError) IsAccessTokenError() bool {
+
+	return e.ErrCode == ERRCODE_INVALID_CREDENTIAL || e.ErrCode == ERRCODE_INVALID_ACCESS_TOKEN ||
+		e.ErrCode == ERRCODE_ACCESS_TOKEN_EXPIRED
+
+}
+
+// The refresh token is invalid or expired, the user should authorize again.
+func (e *APIError) NeedsReauthorization() bool {
+
+	return e.ErrCode == ERRCODE_INVALID_REFRESH_TOKEN || e.ErrCode == ERRCODE_REFRESH_TOKEN_EXPIRED
+
+}
+
+// Calling too frequently, retry later.
+func (e *APIError) IsRateLimited() bool {
+
+	return e.ErrCode == ERRCODE_API_FREQ_OUT_OF_LIMIT
+
+}
@@ -39,7 +39,7 @@ func (o *OAuth2) parseOAuth2Response(result interface{}, resp *http.Response, l
 
 	var body bytes.Buffer
 
-	if err = wx.LimitRead(resp.Body, &body, int64(o.maxResultSize())); err != nil {
+	if err = wx.ReadResponse(resp, &body, int64(o.maxResultSize()), wx.JSONContentTypes...); err != nil {
 
 		return
 
@@ -82,6 +82,16 @@ func (o *OAuth2) callOAuth2API(ctx context.Context, URL string, result interface
 
 	}
 
+	if o.ReturnAPIErrors {
+
+		if r, ok := result.(interface{ Error() error }); ok {
+
+			err = r.Error()
+
+		}
+
+	}
+
 	return
 
 }
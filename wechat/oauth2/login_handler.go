@@ -0,0 +1,470 @@
+package oauth2
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors passed to LoginHandler.OnError.
+var (
+	ErrAuthorizationDenied = errors.New("oauth2: user denied authorization")
+	ErrInvalidState        = errors.New("oauth2: invalid state")
+	ErrStateExpired        = errors.New("oauth2: state expired")
+	ErrStateReplayed       = errors.New("oauth2: state replayed")
+)
+
+// Default value of LoginHandler.StateTTL.
+const DefaultStateTTL = 10 * time.Minute
+
+// Name of the cookie binding a state to the browser.
+const loginCookieName = "wx_oauth2_login"
+
+// The identity of a logged in user passed to LoginHandler.OnLogin.
+type Identity struct {
+	OpenID  string
+	UnionID string
+	Scope   OAuth2Scope
+
+	// The token (already saved in OAuth2.TokenStore).
+	Token *Token
+
+	// Only available when scope is OAUTH2_SCOPE_USERINFO or OAUTH2_SCOPE_LOGIN.
+	UserInfo *UserInfoResult
+
+	// The return URL passed to LoginURL.
+	ReturnURL string
+}
+
+// LoginHandler implements the complete web login flow: it redirects the user to
+// Wechat's authorization page with a signed, expiring state, handles the callback,
+// exchanges the code and hands the user's identity to OnLogin.
+//
+// Mount it at CallbackURL. Visiting it without code/state (e.g. "/wx/login?return_url=/me")
+// starts the login, Wechat redirects back to it with code/state later.
+//
+// A state has the form hex(nonce)+hex(expiry)+hex(HMAC-SHA256(key, nonce|expiry|return_url))
+// which fits Wechat's limit (alphanumeric, 128 bytes). The nonce and return URL are kept
+// in a cookie, so a state is only accepted in the browser starting the login. Used
+// states are remembered (in process) until they expire to reject replays.
+type LoginHandler struct {
+	o   *OAuth2
+	key []byte
+
+	// The absolute URL the handler is mounted at.
+	CallbackURL string
+
+	// Scope to request. Default to OAUTH2_SCOPE_BASE. For OAUTH2_SCOPE_LOGIN (website QR
+	// login), the OAuth2 instance should use AppConfig.WebsiteAppConfig.
+	Scope OAuth2Scope
+
+	// Life time of a state. Default to DefaultStateTTL.
+	StateTTL time.Duration
+
+	// Language passed to UserInfo. Default to LANG_ZH_CN.
+	Lang Language
+
+	// Called after successful login, it should write the response, e.g. set session
+	// and redirect to identity.ReturnURL. Required.
+	OnLogin func(w http.ResponseWriter, r *http.Request, identity *Identity)
+
+	// Called on failure. Default to respond 400 for state errors and 502 otherwise.
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+
+	// Logger used in API calls, can be nil.
+	Logger wx.Logger
+
+	mu   sync.Mutex
+	used map[string]time.Time // nonce -> expiry
+}
+
+// Create a LoginHandler. key is the secret used to sign states, it should be at
+// least 16 bytes.
+func NewLoginHandler(o *OAuth2, key []byte, callback_url string,
+	on_login func(http.ResponseWriter, *http.Request, *Identity)) (*LoginHandler, error) {
+
+	if len(key) < 16 {
+
+		return nil, fmt.Errorf("NewLoginHandler: key too short")
+
+	}
+
+	u, err := url.Parse(callback_url)
+
+	if err != nil || !u.IsAbs() {
+
+		return nil, fmt.Errorf("NewLoginHandler: callback_url must be absolute")
+
+	}
+
+	if on_login == nil {
+
+		return nil, fmt.Errorf("NewLoginHandler: on_login missing")
+
+	}
+
+	return &LoginHandler{
+		o:           o,
+		key:         key,
+		CallbackURL: callback_url,
+		OnLogin:     on_login,
+		used:        make(map[string]time.Time),
+	}, nil
+
+}
+
+func (h *LoginHandler) scope() OAuth2Scope {
+
+	if h.Scope == "" {
+
+		return OAUTH2_SCOPE_BASE
+
+	}
+
+	return h.Scope
+
+}
+
+func (h *LoginHandler) stateTTL() time.Duration {
+
+	if h.StateTTL <= 0 {
+
+		return DefaultStateTTL
+
+	}
+
+	return h.StateTTL
+
+}
+
+// The binding cookie is only sent to the callback.
+func (h *LoginHandler) cookiePath() string {
+
+	if u, err := url.Parse(h.CallbackURL); err == nil && u.Path != "" {
+
+		return u.Path
+
+	}
+
+	return "/"
+
+}
+
+func (h *LoginHandler) mac(nonce, expiry, return_url string) string {
+
+	m := hmac.New(sha256.New, h.key)
+
+	m.Write([]byte(nonce))
+	m.Write([]byte{'|'})
+	m.Write([]byte(expiry))
+	m.Write([]byte{'|'})
+	m.Write([]byte(return_url))
+
+	return hex.EncodeToString(m.Sum(nil))
+
+}
+
+// Only relative paths are allowed as return URL to avoid open redirection. Control
+// characters are rejected too since browsers strip tab/newline ("/\t/evil.com").
+func validReturnURL(return_url string) bool {
+
+	for i := 0; i < len(return_url); i++ {
+
+		if c := return_url[i]; c < 0x20 || c == 0x7f {
+
+			return false
+
+		}
+
+	}
+
+	return return_url == "" ||
+		(strings.HasPrefix(return_url, "/") && !strings.HasPrefix(return_url, "//") &&
+			!strings.HasPrefix(return_url, "/\\"))
+
+}
+
+// Start the login: set the binding cookie and return the URL to redirect the
+// user to. return_url must be a relative path (or empty).
+func (h *LoginHandler) LoginURL(w http.ResponseWriter, return_url string) (string, error) {
+
+	if !validReturnURL(return_url) {
+
+		return "", fmt.Errorf("LoginURL: bad return_url %+q", return_url)
+
+	}
+
+	nonce, err := wx.NonceStr(h.o.NonceSource, 16)
+
+	if err != nil {
+
+		return "", err
+
+	}
+
+	expires_at := wx.Now(h.o.Clock).Add(h.stateTTL())
+
+	expiry := fmt.Sprintf("%08x", expires_at.Unix())
+
+	state := nonce + expiry + h.mac(nonce, expiry, return_url)
+
+	cookie := &http.Cookie{
+		Name:     loginCookieName,
+		Value:    nonce + "|" + url.QueryEscape(return_url),
+		Path:     h.cookiePath(),
+		MaxAge:   int(h.stateTTL() / time.Second),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.CallbackURL, "https:"),
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	http.SetCookie(w, cookie)
+
+	if h.scope() == OAUTH2_SCOPE_LOGIN {
+
+		return h.o.AuthCodeURLQR(h.CallbackURL, state), nil
+
+	}
+
+	return h.o.AuthCodeURL(h.scope(), h.CallbackURL, state), nil
+
+}
+
+// Verify state against the binding cookie, return the return URL.
+func (h *LoginHandler) verifyState(r *http.Request, state string) (string, error) {
+
+	// 16 (nonce) + 8 (expiry) + 64 (mac)
+	if len(state) != 88 {
+
+		return "", ErrInvalidState
+
+	}
+
+	nonce, expiry, mac := state[:16], state[16:24], state[24:]
+
+	cookie, err := r.Cookie(loginCookieName)
+
+	if err != nil {
+
+		return "", ErrInvalidState
+
+	}
+
+	parts := strings.SplitN(cookie.Value, "|", 2)
+
+	if len(parts) != 2 || parts[0] != nonce {
+
+		return "", ErrInvalidState
+
+	}
+
+	return_url, err := url.QueryUnescape(parts[1])
+
+	if err != nil {
+
+		return "", ErrInvalidState
+
+	}
+
+	if !hmac.Equal([]byte(mac), []byte(h.mac(nonce, expiry, return_url))) {
+
+		return "", ErrInvalidState
+
+	}
+
+	exp, err := strconv.ParseInt(expiry, 16, 64)
+
+	if err != nil {
+
+		return "", ErrInvalidState
+
+	}
+
+	expires_at := time.Unix(exp, 0)
+
+	now := wx.Now(h.o.Clock)
+
+	if !now.Before(expires_at) {
+
+		return "", ErrStateExpired
+
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for n, t := range h.used {
+
+		if !now.Before(t) {
+
+			delete(h.used, n)
+
+		}
+
+	}
+
+	if _, ok := h.used[nonce]; ok {
+
+		return "", ErrStateReplayed
+
+	}
+
+	h.used[nonce] = expires_at
+
+	return return_url, nil
+
+}
+
+// Implement http.Handler.
+func (h *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	q := r.URL.Query()
+
+	state := q.Get("state")
+
+	if state == "" {
+
+		login_url, err := h.LoginURL(w, q.Get("return_url"))
+
+		if err != nil {
+
+			h.fail(w, r, err)
+			return
+
+		}
+
+		http.Redirect(w, r, login_url, http.StatusFound)
+		return
+
+	}
+
+	identity, err := h.callback(r, q.Get("code"), state)
+
+	// Clear the binding cookie.
+	http.SetCookie(w, &http.Cookie{
+		Name:   loginCookieName,
+		Path:   h.cookiePath(),
+		MaxAge: -1,
+	})
+
+	if err != nil {
+
+		h.fail(w, r, err)
+		return
+
+	}
+
+	h.OnLogin(w, r, identity)
+
+}
+
+func (h *LoginHandler) callback(r *http.Request, code, state string) (*Identity, error) {
+
+	return_url, err := h.verifyState(r, state)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	// Wechat redirects without code when the user denies.
+	if code == "" {
+
+		return nil, ErrAuthorizationDenied
+
+	}
+
+	ctx := r.Context()
+
+	result, err := h.o.AccessToken(ctx, code, h.Logger)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	token, err := h.o.SaveToken(ctx, result)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	identity := &Identity{
+		OpenID:    token.OpenID,
+		UnionID:   token.UnionID,
+		Scope:     h.scope(),
+		Token:     token,
+		ReturnURL: return_url,
+	}
+
+	if result.HasScope(OAUTH2_SCOPE_USERINFO) || result.HasScope(OAUTH2_SCOPE_LOGIN) {
+
+		info, err := h.o.UserInfo(ctx, token.AccessToken, token.OpenID, h.Lang, h.Logger)
+
+		if err != nil {
+
+			return nil, err
+
+		}
+
+		if err := info.Error(); err != nil {
+
+			return nil, err
+
+		}
+
+		identity.UserInfo = info
+
+		if identity.UnionID == "" {
+
+			identity.UnionID = info.UnionID
+
+		}
+
+	}
+
+	return identity, nil
+
+}
+
+func (h *LoginHandler) fail(w http.ResponseWriter, r *http.Request, err error) {
+
+	if h.OnError != nil {
+
+		h.OnError(w, r, err)
+		return
+
+	}
+
+	switch err {
+
+	case ErrInvalidState, ErrStateExpired, ErrStateReplayed, ErrAuthorizationDenied:
+
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+	default:
+
+		if h.Logger != nil {
+
+			h.Logger.Printf("login_error=%+q\n", err.Error())
+
+		}
+
+		http.Error(w, "login failed", http.StatusBadGateway)
+
+	}
+
+}
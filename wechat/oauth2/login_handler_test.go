@@ -0,0 +1,301 @@
+package oauth2
+
+import (
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/oauth2/oauth2test"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type loginTest struct {
+	t        *testing.T
+	srv      *oauth2test.Server
+	h        *LoginHandler
+	identity *Identity
+	err      error
+}
+
+func newLoginTest(t *testing.T) *loginTest {
+
+	srv := oauth2test.NewServer("app", "secret")
+
+	t.Cleanup(srv.Close)
+
+	srv.AddUser(&oauth2test.User{OpenID: "openid1", Nickname: "alice"})
+
+	o, err := NewOAuth2(&wx.AppConfig{AppID: "app", AppSecret: "secret"}, srv.Client())
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	o.Clock = wx.ClockFunc(srv.Now)
+
+	lt := &loginTest{t: t, srv: srv}
+
+	lt.h, err = NewLoginHandler(o, []byte("0123456789abcdef"), "https://example.com/wx/login",
+		func(w http.ResponseWriter, r *http.Request, identity *Identity) {
+			lt.identity = identity
+		})
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	lt.h.OnError = func(w http.ResponseWriter, r *http.Request, err error) {
+		lt.err = err
+	}
+
+	return lt
+
+}
+
+// Start a login, return the binding cookie and the callback URL after the user
+// authorized.
+func (lt *loginTest) start(return_url string) (*http.Cookie, string) {
+
+	w := httptest.NewRecorder()
+
+	lt.h.ServeHTTP(w, httptest.NewRequest("GET", "/wx/login?"+url.Values{
+		"return_url": []string{return_url},
+	}.Encode(), nil))
+
+	if w.Code != http.StatusFound {
+
+		lt.t.Fatalf("start: expect redirect, got %d %v", w.Code, lt.err)
+
+	}
+
+	cookies := w.Result().Cookies()
+
+	if len(cookies) != 1 || cookies[0].Name != loginCookieName || !cookies[0].HttpOnly {
+
+		lt.t.Fatalf("start: unexpected cookies %v", cookies)
+
+	}
+
+	callback_url, err := lt.srv.Authorize(w.Header().Get("Location"), "openid1")
+
+	if err != nil {
+
+		lt.t.Fatal(err)
+
+	}
+
+	return cookies[0], callback_url
+
+}
+
+// Visit the callback, return the error passed to OnError.
+func (lt *loginTest) callback(cookie *http.Cookie, callback_url string) error {
+
+	lt.identity, lt.err = nil, nil
+
+	r := httptest.NewRequest("GET", callback_url, nil)
+
+	if cookie != nil {
+
+		r.AddCookie(cookie)
+
+	}
+
+	lt.h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if (lt.identity == nil) == (lt.err == nil) {
+
+		lt.t.Fatalf("callback: expect either OnLogin or OnError called")
+
+	}
+
+	return lt.err
+
+}
+
+// Modify the query of a URL.
+func withQuery(u string, f func(q url.Values)) string {
+
+	parsed, _ := url.Parse(u)
+
+	q := parsed.Query()
+
+	f(q)
+
+	parsed.RawQuery = q.Encode()
+
+	return parsed.String()
+
+}
+
+func TestLoginHandler(t *testing.T) {
+
+	lt := newLoginTest(t)
+
+	cookie, callback_url := lt.start("/me?x=1")
+
+	if err := lt.callback(cookie, callback_url); err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if lt.identity.OpenID != "openid1" || lt.identity.ReturnURL != "/me?x=1" || lt.identity.Token == nil {
+
+		t.Fatalf("unexpected identity %+v", lt.identity)
+
+	}
+
+	// Replayed.
+	if err := lt.callback(cookie, callback_url); err != ErrStateReplayed {
+
+		t.Fatalf("expect ErrStateReplayed, got %v", err)
+
+	}
+
+}
+
+func TestLoginHandlerStateErrors(t *testing.T) {
+
+	for _, c := range []struct {
+		Name   string
+		Modify func(lt *loginTest, cookie *http.Cookie, callback_url string) (*http.Cookie, string)
+		Expect error
+	}{
+		{
+			"tampered_mac",
+			func(lt *loginTest, cookie *http.Cookie, callback_url string) (*http.Cookie, string) {
+				return cookie, withQuery(callback_url, func(q url.Values) {
+					state := []byte(q.Get("state"))
+					state[len(state)-1] ^= 1
+					q.Set("state", string(state))
+				})
+			},
+			ErrInvalidState,
+		},
+		{
+			"tampered_return_url",
+			func(lt *loginTest, cookie *http.Cookie, callback_url string) (*http.Cookie, string) {
+				c := *cookie
+				c.Value = c.Value[:16] + "|" + url.QueryEscape("/admin")
+				return &c, callback_url
+			},
+			ErrInvalidState,
+		},
+		{
+			"short_state",
+			func(lt *loginTest, cookie *http.Cookie, callback_url string) (*http.Cookie, string) {
+				return cookie, withQuery(callback_url, func(q url.Values) {
+					q.Set("state", q.Get("state")[1:])
+				})
+			},
+			ErrInvalidState,
+		},
+		{
+			"expired",
+			func(lt *loginTest, cookie *http.Cookie, callback_url string) (*http.Cookie, string) {
+				lt.srv.Advance(DefaultStateTTL + time.Second)
+				return cookie, callback_url
+			},
+			ErrStateExpired,
+		},
+		{
+			"missing_cookie",
+			func(lt *loginTest, cookie *http.Cookie, callback_url string) (*http.Cookie, string) {
+				return nil, callback_url
+			},
+			ErrInvalidState,
+		},
+		{
+			"mismatched_cookie",
+			func(lt *loginTest, cookie *http.Cookie, callback_url string) (*http.Cookie, string) {
+				// Cookie of another login, e.g. the attacker's.
+				other, _ := lt.start("/me")
+				return other, callback_url
+			},
+			ErrInvalidState,
+		},
+		{
+			"denied",
+			func(lt *loginTest, cookie *http.Cookie, callback_url string) (*http.Cookie, string) {
+				return cookie, withQuery(callback_url, func(q url.Values) {
+					q.Del("code")
+				})
+			},
+			ErrAuthorizationDenied,
+		},
+	} {
+
+		lt := newLoginTest(t)
+
+		cookie, callback_url := lt.start("/me")
+
+		cookie, callback_url = c.Modify(lt, cookie, callback_url)
+
+		if err := lt.callback(cookie, callback_url); err != c.Expect {
+
+			t.Errorf("%s: expect %v, got %v", c.Name, c.Expect, err)
+
+		}
+
+	}
+
+}
+
+func TestLoginHandlerReturnURL(t *testing.T) {
+
+	for _, c := range []struct {
+		ReturnURL string
+		OK        bool
+	}{
+		{"", true},
+		{"/", true},
+		{"/me?a=1#x", true},
+		{"//evil.com", false},
+		{"https://evil.com", false},
+		{"/\\evil.com", false},
+		{"/\t/evil.com", false},
+		{"/\n/evil.com", false},
+		{"evil.com", false},
+		{"javascript:alert(1)", false},
+	} {
+
+		lt := newLoginTest(t)
+
+		w := httptest.NewRecorder()
+
+		_, err := lt.h.LoginURL(w, c.ReturnURL)
+
+		if (err == nil) != c.OK {
+
+			t.Errorf("%+q: expect ok=%v, got %v", c.ReturnURL, c.OK, err)
+
+			continue
+
+		}
+
+		if c.OK {
+
+			continue
+
+		}
+
+		// Starting the login from the handler fails as well.
+		lt.h.ServeHTTP(w, httptest.NewRequest("GET", "/wx/login?"+url.Values{
+			"return_url": []string{c.ReturnURL},
+		}.Encode(), nil))
+
+		if lt.err == nil {
+
+			t.Errorf("%+q: expect OnError called", c.ReturnURL)
+
+		}
+
+	}
+
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	wx "github.com/huangjunwen/WechatDriver/wechat"
 	"net/http"
+	"sync"
+	"time"
 )
 
 // Communicate to Wechat's OAuth2 service.
@@ -16,6 +18,26 @@ type OAuth2 struct {
 
 	// Max size when reading incoming result. Default to 4k.
 	MaxResultSize int
+
+	// Source of nonce strings. Default to wx.CryptoNonceSource.
+	NonceSource wx.NonceSource
+
+	// Source of current time. Default to wx.SystemClock.
+	Clock wx.Clock
+
+	// Where users' tokens are kept, used by TokenSource. Default to a MemoryTokenStore.
+	TokenStore TokenStore
+
+	// Refresh access token when it expires within this duration. Default to DefaultRefreshAhead.
+	RefreshAhead time.Duration
+
+	// If true, API methods return *APIError (and nil result) when errcode != 0, so
+	// that callers don't need to check r.Error() separately.
+	ReturnAPIErrors bool
+
+	// In-flight TokenSource.Token calls keyed by appid+openid.
+	mu       sync.Mutex
+	inflight map[[2]string]*tokenCall
 }
 
 // Create OAuth2 instance from app config (and optional a HTTP client). The config
@@ -35,8 +57,9 @@ func NewOAuth2(config *wx.AppConfig, client *http.Client) (*OAuth2, error) {
 	}
 
 	return &OAuth2{
-		config: config,
-		client: client,
+		config:     config,
+		client:     client,
+		TokenStore: NewMemoryTokenStore(),
 	}, nil
 
 }
@@ -45,3 +68,4 @@ func NewOAuth2(config *wx.AppConfig, client *http.Client) (*OAuth2, error) {
 func (o *OAuth2) Config() *wx.AppConfig {
 	return o.config
 }
+
@@ -0,0 +1,652 @@
+// Package oauth2test provides a local stand-in for Wechat's OAuth2/SNS
+// service so that code using oauth2.OAuth2 can be tested without talking to
+// api.weixin.qq.com.
+//
+// Typical usage:
+//
+//	srv := oauth2test.NewServer("appid", "secret")
+//	defer srv.Close()
+//	srv.AddUser(&oauth2test.User{OpenID: "openid1", Nickname: "alice"})
+//
+//	o, _ := oauth2.NewOAuth2(config, srv.Client())
+//	redirect, _ := srv.Authorize(o.AuthCodeURL(oauth2.OAUTH2_SCOPE_USERINFO, cb, csrf), "openid1")
+//	// redirect now carries "code" and "state", exchange it with o.AccessToken.
+package oauth2test
+
+import (
+	"encoding/json"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/internal/wxtest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Error codes returned by the fake server, they are the same as the real service.
+const (
+	ERRCODE_INVALID_CREDENTIAL    = 40001
+	ERRCODE_INVALID_OPENID        = 40003
+	ERRCODE_INVALID_APPID         = 40013
+	ERRCODE_INVALID_CODE          = 40029
+	ERRCODE_INVALID_REFRESH_TOKEN = 40030
+	ERRCODE_INVALID_APPSECRET     = 40125
+	ERRCODE_CODE_BEEN_USED        = 40163
+	ERRCODE_MISSING_CODE          = 41008
+	ERRCODE_ACCESS_TOKEN_EXPIRED  = 42001
+	ERRCODE_REFRESH_TOKEN_EXPIRED = 42002
+	ERRCODE_API_UNAUTHORIZED      = 48001
+)
+
+// A user known by the fake server.
+type User struct {
+	OpenID     string
+	UnionID    string
+	Nickname   string
+	Sex        int
+	Language   string
+	City       string
+	Province   string
+	Country    string
+	Headimgurl string
+	Privilege  []string
+}
+
+type codeEntry struct {
+	openid    string
+	scope     string
+	expiresAt time.Time
+	used      bool
+}
+
+type tokenEntry struct {
+	openid    string
+	scope     string
+	expiresAt time.Time
+}
+
+// Server is a fake Wechat OAuth2/SNS server backed by httptest.Server.
+type Server struct {
+	*httptest.Server
+
+	// App credential accepted by the server.
+	AppID     string
+	AppSecret string
+
+	// Life time of codes, access tokens and refresh tokens. Default to 5 minutes,
+	// 2 hours and 30 days like the real service.
+	CodeTTL         time.Duration
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	mu            sync.Mutex
+	now           time.Time
+	users         map[string]*User
+	codes         map[string]*codeEntry
+	accessTokens  map[string]*tokenEntry
+	refreshTokens map[string]*tokenEntry
+	latency       time.Duration
+	requests      map[string]int
+	injected      map[string][]*injectedError
+}
+
+// Create and start a fake server accepting the given app credential.
+func NewServer(appid, secret string) *Server {
+
+	s := &Server{
+		AppID:           appid,
+		AppSecret:       secret,
+		CodeTTL:         5 * time.Minute,
+		AccessTokenTTL:  7200 * time.Second,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+		now:             time.Now(),
+		users:           make(map[string]*User),
+		codes:           make(map[string]*codeEntry),
+		accessTokens:    make(map[string]*tokenEntry),
+		refreshTokens:   make(map[string]*tokenEntry),
+		requests:        make(map[string]int),
+		injected:        make(map[string][]*injectedError),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sns/oauth2/access_token", s.handleAccessToken)
+	mux.HandleFunc("/sns/oauth2/refresh_token", s.handleRefreshToken)
+	mux.HandleFunc("/sns/userinfo", s.handleUserInfo)
+	mux.HandleFunc("/sns/auth", s.handleAuth)
+
+	s.Server = httptest.NewServer(s.serve(mux))
+
+	return s
+
+}
+
+// Return a HTTP client which sends all requests (whatever the scheme and host
+// are) to the fake server. Pass it to oauth2.NewOAuth2.
+func (s *Server) Client() *http.Client {
+
+	return wxtest.NewClient(s.URL)
+
+}
+
+// Set the delay before responding, e.g. to make concurrent requests overlap.
+func (s *Server) SetLatency(d time.Duration) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency = d
+
+}
+
+// Number of requests received on the API path (e.g. "/sns/oauth2/refresh_token").
+func (s *Server) Requests(path string) int {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[path]
+
+}
+
+// Make the next request on the API path fail with errcode/errmsg, e.g. to simulate
+// rate limiting or system errors.
+func (s *Server) InjectError(path string, errcode int, errmsg string) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.injected[path] = append(s.injected[path], &injectedError{errcode: errcode, errmsg: errmsg})
+
+}
+
+// An error injected by InjectError.
+type injectedError struct {
+	errcode int
+	errmsg  string
+}
+
+func (s *Server) serve(mux *http.ServeMux) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		s.mu.Lock()
+
+		s.requests[r.URL.Path]++
+
+		latency := s.latency
+
+		var injected *injectedError
+
+		if errs := s.injected[r.URL.Path]; len(errs) > 0 {
+
+			injected, s.injected[r.URL.Path] = errs[0], errs[1:]
+
+		}
+
+		s.mu.Unlock()
+
+		if latency > 0 {
+
+			time.Sleep(latency)
+
+		}
+
+		if injected != nil {
+
+			writeError(w, injected.errcode, injected.errmsg)
+			return
+
+		}
+
+		mux.ServeHTTP(w, r)
+
+	}
+
+}
+
+// Current time of the server's clock.
+func (s *Server) Now() time.Time {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.now
+
+}
+
+// Set the server's clock.
+func (s *Server) SetNow(t time.Time) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.now = t
+
+}
+
+// Move the server's clock forward.
+func (s *Server) Advance(d time.Duration) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.now = s.now.Add(d)
+
+}
+
+// Add (or replace) a user.
+func (s *Server) AddUser(u *User) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[u.OpenID] = u
+
+}
+
+// Issue a new code for the user as if the user has authorized the app with scope.
+func (s *Server) IssueCode(openid, scope string) string {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code := s.newID()
+
+	s.codes[code] = &codeEntry{
+		openid:    openid,
+		scope:     scope,
+		expiresAt: s.now.Add(s.CodeTTL),
+	}
+
+	return code
+
+}
+
+// Simulate the user (openid) visiting the URL generated by OAuth2.AuthCodeURL or
+// OAuth2.AuthCodeURLQR and approving the authorization. Return the URL Wechat
+// would redirect the user to (redirect_uri with code and state).
+func (s *Server) Authorize(authURL string, openid string) (string, error) {
+
+	u, err := url.Parse(authURL)
+
+	if err != nil {
+
+		return "", err
+
+	}
+
+	q := u.Query()
+
+	if q.Get("appid") != s.AppID {
+
+		return "", fmt.Errorf("Authorize: unknown appid %+q", q.Get("appid"))
+
+	}
+
+	if q.Get("response_type") != "code" {
+
+		return "", fmt.Errorf("Authorize: unexpected response_type %+q", q.Get("response_type"))
+
+	}
+
+	s.mu.Lock()
+	_, ok := s.users[openid]
+	s.mu.Unlock()
+
+	if !ok {
+
+		return "", fmt.Errorf("Authorize: unknown user %+q", openid)
+
+	}
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+
+	if err != nil || redirect.Scheme == "" {
+
+		return "", fmt.Errorf("Authorize: bad redirect_uri %+q", q.Get("redirect_uri"))
+
+	}
+
+	rq := redirect.Query()
+	rq.Set("code", s.IssueCode(openid, q.Get("scope")))
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+
+	return redirect.String(), nil
+
+}
+
+// Make the access token expired immediately.
+func (s *Server) ExpireAccessToken(token string) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.accessTokens[token]; ok {
+
+		e.expiresAt = s.now
+
+	}
+
+}
+
+// Make the refresh token expired immediately.
+func (s *Server) ExpireRefreshToken(token string) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.refreshTokens[token]; ok {
+
+		e.expiresAt = s.now
+
+	}
+
+}
+
+// Must be called with s.mu held.
+func (s *Server) newID() string {
+
+	for {
+
+		id, err := wx.HexCryptoRandString(32)
+
+		if err != nil {
+
+			panic(err)
+
+		}
+
+		_, c := s.codes[id]
+		_, a := s.accessTokens[id]
+		_, r := s.refreshTokens[id]
+
+		if !c && !a && !r {
+
+			return id
+
+		}
+
+	}
+
+}
+
+// Must be called with s.mu held.
+func (s *Server) issueAccessToken(w http.ResponseWriter, refresh_token string, e *tokenEntry) {
+
+	access_token := s.newID()
+
+	s.accessTokens[access_token] = &tokenEntry{
+		openid:    e.openid,
+		scope:     e.scope,
+		expiresAt: s.now.Add(s.AccessTokenTTL),
+	}
+
+	ret := map[string]interface{}{
+		"access_token":  access_token,
+		"expires_in":    int(s.AccessTokenTTL / time.Second),
+		"refresh_token": refresh_token,
+		"openid":        e.openid,
+		"scope":         e.scope,
+	}
+
+	if u, ok := s.users[e.openid]; ok && u.UnionID != "" {
+
+		ret["unionid"] = u.UnionID
+
+	}
+
+	writeJSON(w, ret)
+
+}
+
+// Must be called with s.mu held.
+func (s *Server) checkApp(w http.ResponseWriter, q url.Values, check_secret bool) bool {
+
+	if q.Get("appid") != s.AppID {
+
+		writeError(w, ERRCODE_INVALID_APPID, "invalid appid")
+		return false
+
+	}
+
+	if check_secret && q.Get("secret") != s.AppSecret {
+
+		writeError(w, ERRCODE_INVALID_APPSECRET, "invalid appsecret")
+		return false
+
+	}
+
+	return true
+
+}
+
+// Must be called with s.mu held.
+func (s *Server) checkAccessToken(w http.ResponseWriter, q url.Values) *tokenEntry {
+
+	e, ok := s.accessTokens[q.Get("access_token")]
+
+	if !ok {
+
+		writeError(w, ERRCODE_INVALID_CREDENTIAL, "invalid credential, access_token is invalid or not latest")
+		return nil
+
+	}
+
+	if !s.now.Before(e.expiresAt) {
+
+		writeError(w, ERRCODE_ACCESS_TOKEN_EXPIRED, "access_token expired")
+		return nil
+
+	}
+
+	if q.Get("openid") != e.openid {
+
+		writeError(w, ERRCODE_INVALID_OPENID, "invalid openid")
+		return nil
+
+	}
+
+	return e
+
+}
+
+func (s *Server) handleAccessToken(w http.ResponseWriter, r *http.Request) {
+
+	q := r.URL.Query()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.checkApp(w, q, true) {
+
+		return
+
+	}
+
+	if q.Get("grant_type") != "authorization_code" {
+
+		writeError(w, 40002, "invalid grant_type")
+		return
+
+	}
+
+	code := q.Get("code")
+
+	if code == "" {
+
+		writeError(w, ERRCODE_MISSING_CODE, "missing code")
+		return
+
+	}
+
+	c, ok := s.codes[code]
+
+	switch {
+
+	case !ok, !s.now.Before(c.expiresAt):
+
+		writeError(w, ERRCODE_INVALID_CODE, "invalid code")
+		return
+
+	case c.used:
+
+		writeError(w, ERRCODE_CODE_BEEN_USED, "code been used")
+		return
+
+	}
+
+	c.used = true
+
+	refresh_token := s.newID()
+
+	e := &tokenEntry{
+		openid:    c.openid,
+		scope:     c.scope,
+		expiresAt: s.now.Add(s.RefreshTokenTTL),
+	}
+
+	s.refreshTokens[refresh_token] = e
+
+	s.issueAccessToken(w, refresh_token, e)
+
+}
+
+func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
+
+	q := r.URL.Query()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.checkApp(w, q, false) {
+
+		return
+
+	}
+
+	if q.Get("grant_type") != "refresh_token" {
+
+		writeError(w, 40002, "invalid grant_type")
+		return
+
+	}
+
+	refresh_token := q.Get("refresh_token")
+
+	e, ok := s.refreshTokens[refresh_token]
+
+	if !ok {
+
+		writeError(w, ERRCODE_INVALID_REFRESH_TOKEN, "invalid refresh_token")
+		return
+
+	}
+
+	if !s.now.Before(e.expiresAt) {
+
+		writeError(w, ERRCODE_REFRESH_TOKEN_EXPIRED, "refresh_token expired")
+		return
+
+	}
+
+	s.issueAccessToken(w, refresh_token, e)
+
+}
+
+func (s *Server) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+
+	q := r.URL.Query()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.checkAccessToken(w, q)
+
+	if e == nil {
+
+		return
+
+	}
+
+	if e.scope != "snsapi_userinfo" && e.scope != "snsapi_login" {
+
+		writeError(w, ERRCODE_API_UNAUTHORIZED, "api unauthorized")
+		return
+
+	}
+
+	u, ok := s.users[e.openid]
+
+	if !ok {
+
+		writeError(w, ERRCODE_INVALID_OPENID, "invalid openid")
+		return
+
+	}
+
+	privilege := u.Privilege
+
+	if privilege == nil {
+
+		privilege = []string{}
+
+	}
+
+	ret := map[string]interface{}{
+		"openid":     u.OpenID,
+		"nickname":   u.Nickname,
+		"sex":        u.Sex,
+		"language":   u.Language,
+		"city":       u.City,
+		"province":   u.Province,
+		"country":    u.Country,
+		"headimgurl": u.Headimgurl,
+		"privilege":  privilege,
+	}
+
+	if u.UnionID != "" {
+
+		ret["unionid"] = u.UnionID
+
+	}
+
+	writeJSON(w, ret)
+
+}
+
+func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request) {
+
+	q := r.URL.Query()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.checkAccessToken(w, q) == nil {
+
+		return
+
+	}
+
+	writeError(w, 0, "ok")
+
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+
+	w.Header().Set("Content-Type", "application/json; encoding=utf-8")
+
+	json.NewEncoder(w).Encode(v)
+
+}
+
+func writeError(w http.ResponseWriter, errcode int, errmsg string) {
+
+	writeJSON(w, map[string]interface{}{
+		"errcode": errcode,
+		"errmsg":  errmsg,
+	})
+
+}
@@ -0,0 +1,300 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"time"
+)
+
+// Returned by TokenSource.Token when there is no usable token for the user (never
+// authorized, or the refresh token has expired/been revoked). The caller should
+// redirect the user to AuthCodeURL again.
+var ErrReauthorizationRequired = errors.New("oauth2: re-authorization required")
+
+// Default value of OAuth2.RefreshAhead.
+const DefaultRefreshAhead = 5 * time.Minute
+
+// Convert AccessToken/RefreshAccessToken result into Token and save it to the
+// token store. Call this after a successful AccessToken call so that TokenSource
+// can use it.
+func (o *OAuth2) SaveToken(ctx context.Context, r *AccessTokenResult) (*Token, error) {
+
+	if err := r.Error(); err != nil {
+
+		return nil, err
+
+	}
+
+	now := wx.Now(o.Clock)
+
+	token := &Token{
+		AppID:            o.config.AppID,
+		OpenID:           r.OpenID,
+		UnionID:          r.UnionID,
+		AccessToken:      r.AccessToken,
+		RefreshToken:     r.RefreshToken,
+		Scope:            r.Scope,
+		ExpiresAt:        now.Add(time.Duration(r.ExpiresIn) * time.Second),
+		RefreshExpiresAt: now.Add(RefreshTokenLifetime),
+	}
+
+	if err := o.TokenStore.Save(ctx, token); err != nil {
+
+		return nil, err
+
+	}
+
+	return token, nil
+
+}
+
+// TokenSource returns valid access token of a user, refreshing it when needed.
+// Concurrent calls for the same user (even from different TokenSources of the
+// same OAuth2) are deduplicated.
+type TokenSource struct {
+	o      *OAuth2
+	openid string
+
+	// If true, a stored access token which is not about to expire is still checked
+	// by CheckAccessToken before returned, and refreshed only if it is reported
+	// invalid/expired (40001/40014/42001, e.g. superseded by another login). Other
+	// errcodes are returned as *APIError. Cost an extra API call each time.
+	Verify bool
+}
+
+// Return a TokenSource for the user. Tokens are loaded from/saved to o.TokenStore.
+func (o *OAuth2) TokenSource(openid string) *TokenSource {
+
+	return &TokenSource{
+		o:      o,
+		openid: openid,
+	}
+
+}
+
+// An in-flight TokenSource.Token shared by callers for the same user.
+type tokenCall struct {
+	done   chan struct{}
+	verify bool
+	token  *Token
+	err    error
+}
+
+// Return a valid token. If the access token is about to expire (see
+// OAuth2.RefreshAhead), it is refreshed transparently using RefreshAccessToken.
+// Return ErrReauthorizationRequired if no token stored or the refresh token is no
+// longer usable.
+//
+// The shared call runs on its own context (bounded by wx.DefaultAPITimeout) so
+// that a canceled caller does not fail the others; each caller only gives up
+// waiting when its own ctx is done.
+func (ts *TokenSource) Token(ctx context.Context, l wx.Logger) (*Token, error) {
+
+	o := ts.o
+
+	key := [2]string{o.config.AppID, ts.openid}
+
+	for {
+
+		o.mu.Lock()
+
+		c := o.inflight[key]
+
+		if c == nil {
+
+			if o.inflight == nil {
+
+				o.inflight = make(map[[2]string]*tokenCall)
+
+			}
+
+			c = &tokenCall{done: make(chan struct{}), verify: ts.Verify}
+
+			o.inflight[key] = c
+
+			go ts.doCall(c, key, l)
+
+		}
+
+		o.mu.Unlock()
+
+		select {
+
+		case <-c.done:
+
+		case <-ctx.Done():
+
+			return nil, ctx.Err()
+
+		}
+
+		// A verifying caller does not accept the result of a non-verifying call.
+		if c.verify || !ts.Verify {
+
+			if c.err != nil {
+
+				return nil, c.err
+
+			}
+
+			token := *c.token
+
+			return &token, nil
+
+		}
+
+	}
+
+}
+
+func (ts *TokenSource) doCall(c *tokenCall, key [2]string, l wx.Logger) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), wx.DefaultAPITimeout)
+
+	defer cancel()
+
+	c.token, c.err = ts.token(ctx, c.verify, l)
+
+	ts.o.mu.Lock()
+
+	delete(ts.o.inflight, key)
+
+	ts.o.mu.Unlock()
+
+	close(c.done)
+
+}
+
+func (ts *TokenSource) token(ctx context.Context, verify bool, l wx.Logger) (*Token, error) {
+
+	o := ts.o
+
+	appid := o.config.AppID
+
+	token, err := o.TokenStore.Load(ctx, appid, ts.openid)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	if token == nil {
+
+		return nil, ErrReauthorizationRequired
+
+	}
+
+	now := wx.Now(o.Clock)
+
+	refresh_ahead := o.RefreshAhead
+
+	if refresh_ahead <= 0 {
+
+		refresh_ahead = DefaultRefreshAhead
+
+	}
+
+	if now.Add(refresh_ahead).Before(token.ExpiresAt) {
+
+		if !verify {
+
+			return token, nil
+
+		}
+
+		r, err := o.CheckAccessToken(ctx, token.AccessToken, token.OpenID, l)
+
+		if err != nil {
+
+			return nil, err
+
+		}
+
+		switch r.Validity() {
+
+		case TOKEN_VALID:
+
+			return token, nil
+
+		case TOKEN_UNKNOWN:
+
+			return nil, r.Error()
+
+		}
+
+	}
+
+	if !now.Before(token.RefreshExpiresAt) {
+
+		return nil, ts.reauthorize(ctx)
+
+	}
+
+	r, err := o.RefreshAccessToken(ctx, token.RefreshToken, l)
+
+	if err == nil {
+
+		err = r.Error()
+
+	}
+
+	var api_err *APIError
+
+	if errors.As(err, &api_err) && api_err.NeedsReauthorization() {
+
+		return nil, ts.reauthorize(ctx)
+
+	}
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	if r.OpenID != "" && r.OpenID != ts.openid {
+
+		return nil, fmt.Errorf("TokenSource: openid mismatch %+q != %+q", r.OpenID, ts.openid)
+
+	}
+
+	token.AccessToken = r.AccessToken
+
+	token.ExpiresAt = now.Add(time.Duration(r.ExpiresIn) * time.Second)
+
+	if r.RefreshToken != "" {
+
+		token.RefreshToken = r.RefreshToken
+
+	}
+
+	if r.Scope != "" {
+
+		token.Scope = r.Scope
+
+	}
+
+	if err := o.TokenStore.Save(ctx, token); err != nil {
+
+		return nil, err
+
+	}
+
+	return token, nil
+
+}
+
+func (ts *TokenSource) reauthorize(ctx context.Context) error {
+
+	if err := ts.o.TokenStore.Delete(ctx, ts.o.config.AppID, ts.openid); err != nil {
+
+		return err
+
+	}
+
+	return ErrReauthorizationRequired
+
+}
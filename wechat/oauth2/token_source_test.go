@@ -0,0 +1,264 @@
+package oauth2
+
+import (
+	"context"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/oauth2/oauth2test"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Create an OAuth2 talking to a fake server (sharing its clock), with a token of
+// "openid1" obtained and saved.
+func newTestOAuth2(t *testing.T) (*OAuth2, *oauth2test.Server, *Token) {
+
+	srv := oauth2test.NewServer("app", "secret")
+
+	t.Cleanup(srv.Close)
+
+	srv.AddUser(&oauth2test.User{OpenID: "openid1", Nickname: "alice"})
+
+	o, err := NewOAuth2(&wx.AppConfig{AppID: "app", AppSecret: "secret"}, srv.Client())
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	o.Clock = wx.ClockFunc(srv.Now)
+
+	ctx := context.Background()
+
+	r, err := o.AccessToken(ctx, srv.IssueCode("openid1", string(OAUTH2_SCOPE_USERINFO)), nil)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	token, err := o.SaveToken(ctx, r)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	return o, srv, token
+
+}
+
+// Concurrent calls from different TokenSources of the same user refresh once.
+func TestTokenSourceDeduplicated(t *testing.T) {
+
+	o, srv, token := newTestOAuth2(t)
+
+	srv.Advance(2 * time.Hour)
+
+	srv.SetLatency(50 * time.Millisecond)
+
+	var wg sync.WaitGroup
+
+	tokens := make([]string, 10)
+
+	for i := range tokens {
+
+		wg.Add(1)
+
+		go func(i int) {
+
+			defer wg.Done()
+
+			token, err := o.TokenSource("openid1").Token(context.Background(), nil)
+
+			if err != nil {
+
+				t.Error(err)
+
+				return
+
+			}
+
+			tokens[i] = token.AccessToken
+
+		}(i)
+
+	}
+
+	wg.Wait()
+
+	if n := srv.Requests("/sns/oauth2/refresh_token"); n != 1 {
+
+		t.Fatalf("expect 1 refresh, got %d", n)
+
+	}
+
+	for _, access_token := range tokens {
+
+		if access_token == token.AccessToken || access_token != tokens[0] {
+
+			t.Fatalf("unexpected access tokens %v", tokens)
+
+		}
+
+	}
+
+}
+
+// The refresh token expired: the stored token is removed.
+func TestTokenSourceReauthorize(t *testing.T) {
+
+	o, srv, _ := newTestOAuth2(t)
+
+	srv.Advance(31 * 24 * time.Hour)
+
+	ctx := context.Background()
+
+	if _, err := o.TokenSource("openid1").Token(ctx, nil); err != ErrReauthorizationRequired {
+
+		t.Fatalf("expect ErrReauthorizationRequired, got %v", err)
+
+	}
+
+	if token, _ := o.TokenStore.Load(ctx, "app", "openid1"); token != nil {
+
+		t.Fatalf("expect token deleted, got %+v", token)
+
+	}
+
+	if _, err := o.TokenSource("openid2").Token(ctx, nil); err != ErrReauthorizationRequired {
+
+		t.Fatalf("expect ErrReauthorizationRequired for unknown user, got %v", err)
+
+	}
+
+}
+
+// Verify refreshes only on access token errcodes.
+func TestTokenSourceVerify(t *testing.T) {
+
+	for _, c := range []struct {
+		ErrCode   int // Returned by sns/auth, injected unless 0 or ERRCODE_ACCESS_TOKEN_EXPIRED
+		Refreshed bool
+	}{
+		{0, false},
+		{ERRCODE_INVALID_CREDENTIAL, true},
+		{ERRCODE_INVALID_ACCESS_TOKEN, true},
+		{ERRCODE_ACCESS_TOKEN_EXPIRED, true},
+		{ERRCODE_API_FREQ_OUT_OF_LIMIT, false},
+		{-1, false},
+	} {
+
+		o, srv, stored := newTestOAuth2(t)
+
+		switch c.ErrCode {
+
+		case 0:
+
+		case ERRCODE_ACCESS_TOKEN_EXPIRED:
+
+			// Expired on server only (e.g. superseded).
+			srv.ExpireAccessToken(stored.AccessToken)
+
+		default:
+
+			srv.InjectError("/sns/auth", c.ErrCode, "injected")
+
+		}
+
+		ts := o.TokenSource("openid1")
+
+		ts.Verify = true
+
+		token, err := ts.Token(context.Background(), nil)
+
+		if srv.Requests("/sns/auth") != 1 {
+
+			t.Fatalf("%d: expect sns/auth checked", c.ErrCode)
+
+		}
+
+		if refreshed := srv.Requests("/sns/oauth2/refresh_token") == 1; refreshed != c.Refreshed {
+
+			t.Fatalf("%d: expect refreshed %v, got %v", c.ErrCode, c.Refreshed, refreshed)
+
+		}
+
+		if c.ErrCode == 0 || c.Refreshed {
+
+			if err != nil {
+
+				t.Fatalf("%d: unexpected error %v", c.ErrCode, err)
+
+			}
+
+			if (token.AccessToken != stored.AccessToken) != c.Refreshed {
+
+				t.Fatalf("%d: unexpected access token %+q", c.ErrCode, token.AccessToken)
+
+			}
+
+			continue
+
+		}
+
+		api_err, ok := err.(*APIError)
+
+		if !ok || api_err.ErrCode != c.ErrCode {
+
+			t.Fatalf("%d: expect *APIError, got %v", c.ErrCode, err)
+
+		}
+
+	}
+
+}
+
+func TestFileTokenStorePath(t *testing.T) {
+
+	s, err := NewFileTokenStore(t.TempDir())
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if s.path("a_b", "c") == s.path("a", "b_c") {
+
+		t.Fatal("different appid/openid pairs map to the same file")
+
+	}
+
+	ctx := context.Background()
+
+	if err := s.Save(ctx, &Token{AppID: "a_b", OpenID: "c", AccessToken: "1"}); err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if err := s.Save(ctx, &Token{AppID: "a", OpenID: "b_c", AccessToken: "2"}); err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	token, err := s.Load(ctx, "a_b", "c")
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if token == nil || token.AccessToken != "1" {
+
+		t.Fatalf("unexpected token %+v", token)
+
+	}
+
+}
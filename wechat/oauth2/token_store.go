@@ -0,0 +1,215 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Life time of refresh token: 30 days after user's authorization.
+const RefreshTokenLifetime = 30 * 24 * time.Hour
+
+// A user's access token (and refresh token) kept in TokenStore.
+type Token struct {
+	AppID        string    `json:"app_id"`
+	OpenID       string    `json:"openid"`
+	UnionID      string    `json:"unionid,omitempty"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Scope        string    `json:"scope"` // "," seperated
+	ExpiresAt    time.Time `json:"expires_at"`
+	// When the refresh token expires, the user must authorize again.
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// Storage of users' tokens keyed by appid+openid. Implementations must be safe
+// for concurrent use.
+type TokenStore interface {
+	// Load a token. Return nil token (and nil error) if not found.
+	Load(ctx context.Context, appid, openid string) (*Token, error)
+
+	// Save (or replace) a token.
+	Save(ctx context.Context, token *Token) error
+
+	// Delete a token. Deleting a non-existed token is not an error.
+	Delete(ctx context.Context, appid, openid string) error
+}
+
+// In-memory TokenStore.
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[[2]string]Token
+}
+
+// Create an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+
+	return &MemoryTokenStore{
+		tokens: make(map[[2]string]Token),
+	}
+
+}
+
+func (s *MemoryTokenStore) Load(ctx context.Context, appid, openid string) (*Token, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[[2]string{appid, openid}]
+
+	if !ok {
+
+		return nil, nil
+
+	}
+
+	return &token, nil
+
+}
+
+func (s *MemoryTokenStore) Save(ctx context.Context, token *Token) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[[2]string{token.AppID, token.OpenID}] = *token
+
+	return nil
+
+}
+
+func (s *MemoryTokenStore) Delete(ctx context.Context, appid, openid string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, [2]string{appid, openid})
+
+	return nil
+
+}
+
+// File-backed TokenStore, each token is stored as a JSON file in a directory.
+type FileTokenStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Create a FileTokenStore using dir (created if not exists).
+func NewFileTokenStore(dir string) (*FileTokenStore, error) {
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+
+		return nil, err
+
+	}
+
+	return &FileTokenStore{
+		dir: dir,
+	}, nil
+
+}
+
+// Components are hex encoded so that different appid/openid pairs never map to
+// the same file.
+func (s *FileTokenStore) path(appid, openid string) string {
+
+	name := hex.EncodeToString([]byte(appid)) + "_" + hex.EncodeToString([]byte(openid)) + ".json"
+
+	return filepath.Join(s.dir, name)
+
+}
+
+func (s *FileTokenStore) Load(ctx context.Context, appid, openid string) (*Token, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := ioutil.ReadFile(s.path(appid, openid))
+
+	if os.IsNotExist(err) {
+
+		return nil, nil
+
+	}
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	token := &Token{}
+
+	if err := json.Unmarshal(data, token); err != nil {
+
+		return nil, err
+
+	}
+
+	return token, nil
+
+}
+
+func (s *FileTokenStore) Save(ctx context.Context, token *Token) error {
+
+	data, err := json.Marshal(token)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Write to a temp file then rename, so readers never see partial content.
+	f, err := ioutil.TempFile(s.dir, ".token-")
+
+	if err != nil {
+
+		return err
+
+	}
+
+	if _, err := f.Write(data); err != nil {
+
+		f.Close()
+		os.Remove(f.Name())
+		return err
+
+	}
+
+	if err := f.Close(); err != nil {
+
+		os.Remove(f.Name())
+		return err
+
+	}
+
+	return os.Rename(f.Name(), s.path(token.AppID, token.OpenID))
+
+}
+
+func (s *FileTokenStore) Delete(ctx context.Context, appid, openid string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(appid, openid))
+
+	if os.IsNotExist(err) {
+
+		return nil
+
+	}
+
+	return err
+
+}
@@ -1,17 +1,93 @@
 package oauth2
 
-import (
-	"fmt"
-)
-
 type OAuth2Scope string
 
 const (
 	OAUTH2_SCOPE_BASE     OAuth2Scope = "snsapi_base"
 	OAUTH2_SCOPE_USERINFO OAuth2Scope = "snsapi_userinfo"
-	//OAUTH2_SCOPE_LOGIN    OAuth2Scope = "snsapi_login"
+	// Website QR login, only for open platform website apps. See AuthCodeURLQR.
+	OAUTH2_SCOPE_LOGIN OAuth2Scope = "snsapi_login"
+)
+
+// Sex of user.
+type Sex int
+
+const (
+	SEX_UNKNOWN Sex = 0
+	SEX_MALE    Sex = 1
+	SEX_FEMALE  Sex = 2
+)
+
+func (s Sex) String() string {
+
+	switch s {
+
+	case SEX_MALE:
+
+		return "male"
+
+	case SEX_FEMALE:
+
+		return "female"
+
+	default:
+
+		return "unknown"
+
+	}
+
+}
+
+// Language of user info.
+type Language string
+
+const (
+	LANG_ZH_CN Language = "zh_CN"
+	LANG_ZH_TW Language = "zh_TW"
+	LANG_EN    Language = "en"
 )
 
+// Is it one of the supported languages ?
+func (lang Language) Valid() bool {
+
+	switch lang {
+
+	case LANG_ZH_CN, LANG_ZH_TW, LANG_EN:
+
+		return true
+
+	default:
+
+		return false
+
+	}
+
+}
+
+// User privilege, e.g. "chinaunicom" (China Unicom WoCard user).
+type Privilege string
+
+const (
+	PRIVILEGE_CHINAUNICOM Privilege = "chinaunicom"
+)
+
+// Is it a known privilege ?
+func (p Privilege) Known() bool {
+
+	switch p {
+
+	case PRIVILEGE_CHINAUNICOM:
+
+		return true
+
+	default:
+
+		return false
+
+	}
+
+}
+
 // Common part of OAuth2 API result.
 type OAuth2ResultBase struct {
 	// ErrCode default to 0, which is good since no errcode means success
@@ -26,7 +102,7 @@ func (r *OAuth2ResultBase) OK() bool {
 
 }
 
-// Return error if not OK().
+// Return *APIError if not OK().
 func (r *OAuth2ResultBase) Error() error {
 
 	if r.OK() {
@@ -35,6 +111,9 @@ func (r *OAuth2ResultBase) Error() error {
 
 	}
 
-	return fmt.Errorf("errcode=%v errmsg=%+q", r.ErrCode, r.ErrMsg)
+	return &APIError{
+		ErrCode: r.ErrCode,
+		ErrMsg:  r.ErrMsg,
+	}
 
 }
@@ -2,42 +2,111 @@ package oauth2
 
 import (
 	"context"
+	"fmt"
 	wx "github.com/huangjunwen/WechatDriver/wechat"
 	"net/url"
+	"strings"
 )
 
 // The result of OAuth2.UserInfo
 type UserInfoResult struct {
 	OAuth2ResultBase
 
-	OpenID   string `json:"openid"`
-	UnionID  string `json:"unionid"`
-	Nickname string `json:"nickname"`
-	Language string `json:"language"`
-	// ....sex...
-	Sex        int      `json:"sex"`
-	City       string   `json:"city"`
-	Province   string   `json:"province"`
-	Country    string   `json:"country"`
-	Headimgurl string   `json:"headimgurl"`
-	Privilege  []string `json:"privilege"`
+	OpenID     string      `json:"openid"`
+	UnionID    string      `json:"unionid"`
+	Nickname   string      `json:"nickname"`
+	Language   Language    `json:"language"`
+	Sex        Sex         `json:"sex"`
+	City       string      `json:"city"`
+	Province   string      `json:"province"`
+	Country    string      `json:"country"`
+	Headimgurl string      `json:"headimgurl"`
+	Privilege  []Privilege `json:"privilege"`
 }
 
-// Get user information from access_token. Return error only when there
-// is error in transport. Caller should also check r.Error().
-func (o *OAuth2) UserInfo(ctx context.Context, access_token, openid, lang string, l wx.Logger) (
+// Return the user's head image URL of the given size, or "" if the user has no
+// head image.
+func (r *UserInfoResult) HeadImgURL(size HeadImgSize) string {
+
+	return HeadImgURLWithSize(r.Headimgurl, size)
+
+}
+
+// HasPrivilege check a privilege's existence.
+func (r *UserInfoResult) HasPrivilege(privilege Privilege) bool {
+	for _, p := range r.Privilege {
+		if p == privilege {
+			return true
+		}
+	}
+	return false
+}
+
+// Get user information from access_token. lang can be empty (default to LANG_ZH_CN).
+// Return error only when there is error in transport (or errcode != 0 when ReturnAPIErrors
+// is set). Caller should also check r.Error().
+func (o *OAuth2) UserInfo(ctx context.Context, access_token, openid string, lang Language, l wx.Logger) (
 	*UserInfoResult, error) {
 
+	if lang == "" {
+
+		lang = LANG_ZH_CN
+
+	}
+
+	if !lang.Valid() {
+
+		return nil, fmt.Errorf("UserInfo: Not supported lang %+q", string(lang))
+
+	}
+
 	r := &UserInfoResult{}
 
 	if err := o.callOAuth2API(ctx,
 		"https://api.weixin.qq.com/sns/userinfo?"+url.Values{
 			"access_token": []string{access_token},
 			"openid":       []string{openid},
-			"lang":         []string{lang},
+			"lang":         []string{string(lang)},
 		}.Encode(), r, l); err != nil {
 		return nil, err
 	}
 	return r, nil
 
 }
+
+// Size of head image.
+type HeadImgSize int
+
+const (
+	HEADIMG_SIZE_640 HeadImgSize = 0
+	HEADIMG_SIZE_46  HeadImgSize = 46
+	HEADIMG_SIZE_64  HeadImgSize = 64
+	HEADIMG_SIZE_96  HeadImgSize = 96
+	HEADIMG_SIZE_132 HeadImgSize = 132
+)
+
+// Replace the size part (the last path segment, one of 0/46/64/96/132) of a
+// headimgurl. Return headimgurl unchanged if it has no such part.
+func HeadImgURLWithSize(headimgurl string, size HeadImgSize) string {
+
+	i := strings.LastIndex(headimgurl, "/")
+
+	if i < 0 {
+
+		return headimgurl
+
+	}
+
+	switch headimgurl[i+1:] {
+
+	case "0", "46", "64", "96", "132":
+
+		return fmt.Sprintf("%s/%d", headimgurl[:i], int(size))
+
+	default:
+
+		return headimgurl
+
+	}
+
+}
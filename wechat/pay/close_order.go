@@ -26,7 +26,11 @@ func (pay *Pay) CloseOrder(ctx context.Context, p *CloseOrderParam, l wx.Logger)
 
 	}
 
-	p.PayParam.fillFrom(pay)
+	if err = p.PayParam.fillFrom(pay); err != nil {
+
+		return nil, err
+
+	}
 
 	r = &CloseOrderResult{}
 
@@ -0,0 +1,33 @@
+package pay
+
+import (
+	"fmt"
+)
+
+// ResultError is a failed pay result: return_code or result_code is not SUCCESS,
+// or the sign is not verified. Use errors.As to extract it.
+type ResultError struct {
+	ReturnCode   string
+	ReturnMsg    string
+	ResultCode   string
+	ErrCode      string
+	ErrCodeDes   string
+	SignVerified bool
+}
+
+func (e *ResultError) Error() string {
+
+	return fmt.Sprintf(
+		"return_code=%+q return_msg=%+q result_code=%+q err_code=%+q err_code_des=%+q sign_verified=%v",
+		e.ReturnCode, e.ReturnMsg, e.ResultCode, e.ErrCode, e.ErrCodeDes, e.SignVerified,
+	)
+
+}
+
+// Is it a system error (err_code SYSTEMERROR) ? The outcome is unknown then: retry
+// with the same parameters (e.g. the same partner_trade_no) or query it.
+func (e *ResultError) IsSystemError() bool {
+
+	return e.SignVerified && e.ErrCode == "SYSTEMERROR"
+
+}
@@ -77,8 +77,39 @@ func (pay *Pay) signFunction(sign_type SignType) func(map[string]string) string
 
 }
 
+// Sign a dict with the pay key and return the hex digest in lower case. Empty
+// sign_type means DefaultSignType. "sign" in dict (if any) is not signed.
+func (pay *Pay) Sign(dict map[string]string, sign_type SignType) string {
+
+	if sign, ok := dict["sign"]; ok {
+
+		delete(dict, "sign")
+
+		defer func() { dict["sign"] = sign }()
+
+	}
+
+	return pay.signFunction(sign_type)(dict)
+
+}
+
+// How a pay API signs requests and results.
+type payCall struct {
+	signType SignType
+
+	// Send sign_type parameter. APIs only supporting MD5 (e.g. mmpaymkttransfers)
+	// do not accept it.
+	sendSignType bool
+
+	// Accept results without sign. It's only for APIs documented to return results
+	// without sign (the transfer APIs), whose authenticity relies on the TLS
+	// connection to api.mch.weixin.qq.com instead. Even then a sign, if present,
+	// must match.
+	unsignedResult bool
+}
+
 // Low level method to sign and encode pay parameters (ptr to struct) into buffer.
-func (pay *Pay) encodeParam(param interface{}, sign_type SignType) (buf *bytes.Buffer, err error) {
+func (pay *Pay) encodeParam(param interface{}, c *payCall) (buf *bytes.Buffer, err error) {
 
 	buf = nil
 
@@ -96,11 +127,13 @@ func (pay *Pay) encodeParam(param interface{}, sign_type SignType) (buf *bytes.B
 
 	delete(dict, "sign")
 
-	sign_type = pay.normalizeSignType(sign_type)
+	if c.sendSignType {
 
-	dict["sign_type"] = string(sign_type)
+		dict["sign_type"] = string(c.signType)
 
-	dict["sign"] = pay.signFunction(sign_type)(dict)
+	}
+
+	dict["sign"] = pay.signFunction(c.signType)(dict)
 
 	// dict -> payXML
 	pay_xml := &payXML{}
@@ -114,8 +147,9 @@ func (pay *Pay) encodeParam(param interface{}, sign_type SignType) (buf *bytes.B
 
 }
 
-// Low level method to decode and verifiy pay result into ptr to struct.
-func (pay *Pay) decodeResult(r io.Reader, result interface{}, sign_type SignType) (err error) {
+// Low level method to decode and verifiy pay result into ptr to struct. Return
+// *ResultError if the result is not successful or not verified.
+func (pay *Pay) decodeResult(r io.Reader, result interface{}, c *payCall) (err error) {
 
 	// io.Reader -> payXML.
 	pay_xml := &payXML{}
@@ -136,34 +170,28 @@ func (pay *Pay) decodeResult(r io.Reader, result interface{}, sign_type SignType
 
 	sign_verified := false
 
-	if sign != "" && strings.ToLower(sign) == pay.signFunction(sign_type)(dict) {
-
-		sign_verified = true
-
-	}
-
 	if has_sign {
 
-		dict["sign"] = sign
+		sign_verified = sign != "" && strings.ToLower(sign) == pay.signFunction(c.signType)(dict)
 
-	}
-
-	return_code, _ := dict["return_code"]
-
-	return_msg, _ := dict["return_msg"]
+		dict["sign"] = sign
 
-	result_code, _ := dict["result_code"]
+	} else {
 
-	err_code, _ := dict["err_code"]
+		sign_verified = c.unsignedResult
 
-	err_code_des, _ := dict["err_code_des"]
+	}
 
-	if return_code != "SUCCESS" || result_code != "SUCCESS" || !sign_verified {
+	if dict["return_code"] != "SUCCESS" || dict["result_code"] != "SUCCESS" || !sign_verified {
 
-		err = fmt.Errorf(
-			"return_code=%+q return_msg=%+q result_code=%+q err_code=%+q err_code_des=%+q sign_verified=%v",
-			return_code, return_msg, result_code, err_code, err_code_des, sign_verified,
-		)
+		err = &ResultError{
+			ReturnCode:   dict["return_code"],
+			ReturnMsg:    dict["return_msg"],
+			ResultCode:   dict["result_code"],
+			ErrCode:      dict["err_code"],
+			ErrCodeDes:   dict["err_code_des"],
+			SignVerified: sign_verified,
+		}
 
 		return
 
@@ -176,12 +204,12 @@ func (pay *Pay) decodeResult(r io.Reader, result interface{}, sign_type SignType
 
 }
 
-func (pay *Pay) preparePayRequest(param interface{}, URL string, sign_type SignType, l wx.Logger) (
+func (pay *Pay) preparePayRequest(param interface{}, URL string, c *payCall, l wx.Logger) (
 	req *http.Request, err error) {
 
 	var body *bytes.Buffer
 
-	if body, err = pay.encodeParam(param, sign_type); err != nil {
+	if body, err = pay.encodeParam(param, c); err != nil {
 
 		return
 
@@ -199,12 +227,12 @@ func (pay *Pay) preparePayRequest(param interface{}, URL string, sign_type SignT
 
 }
 
-func (pay *Pay) parsePayResponse(result interface{}, resp *http.Response, sign_type SignType,
+func (pay *Pay) parsePayResponse(result interface{}, resp *http.Response, c *payCall,
 	l wx.Logger) (err error) {
 
 	var body bytes.Buffer
 
-	if err = wx.LimitRead(resp.Body, &body, int64(pay.maxResultSize())); err != nil {
+	if err = wx.ReadResponse(resp, &body, int64(pay.maxResultSize()), wx.XMLContentTypes...); err != nil {
 
 		return
 
@@ -216,23 +244,21 @@ func (pay *Pay) parsePayResponse(result interface{}, resp *http.Response, sign_t
 
 	}
 
-	err = pay.decodeResult(&body, result, sign_type)
+	err = pay.decodeResult(&body, result, c)
 
 	return
 
 }
 
-func (pay *Pay) callPayPAI(ctx context.Context, URL string, param interface{},
-	result interface{}, l wx.Logger) (err error) {
+func (pay *Pay) callPayAPI(ctx context.Context, URL string, param interface{},
+	result interface{}, c *payCall, l wx.Logger) (err error) {
 
 	var (
 		req  *http.Request
 		resp *http.Response
 	)
 
-	sign_type := pay.normalizeSignType(pay.DefaultSignType)
-
-	if req, err = pay.preparePayRequest(param, URL, sign_type, l); err != nil {
+	if req, err = pay.preparePayRequest(param, URL, c, l); err != nil {
 
 		return
 
@@ -246,12 +272,30 @@ func (pay *Pay) callPayPAI(ctx context.Context, URL string, param interface{},
 
 	}
 
-	if err = pay.parsePayResponse(result, resp, sign_type, l); err != nil {
+	err = pay.parsePayResponse(result, resp, c, l)
 
-		return
+	return
 
-	}
+}
 
-	return
+func (pay *Pay) callPayPAI(ctx context.Context, URL string, param interface{},
+	result interface{}, l wx.Logger) (err error) {
+
+	return pay.callPayAPI(ctx, URL, param, result, &payCall{
+		signType:     pay.normalizeSignType(pay.DefaultSignType),
+		sendSignType: true,
+	}, l)
+
+}
+
+// Like callPayPAI but for APIs (e.g. mmpaymkttransfers) which only support MD5
+// sign without a sign_type parameter. See payCall.unsignedResult.
+func (pay *Pay) callMD5PayAPI(ctx context.Context, URL string, param interface{},
+	result interface{}, unsigned_result bool, l wx.Logger) (err error) {
+
+	return pay.callPayAPI(ctx, URL, param, result, &payCall{
+		signType:       SIGN_TYPE_MD5,
+		unsignedResult: unsigned_result,
+	}, l)
 
 }
@@ -158,7 +158,11 @@ func (pay *Pay) OrderQuery(ctx context.Context, p *OrderQueryParam, l wx.Logger)
 
 	}
 
-	p.PayParam.fillFrom(pay)
+	if err = p.PayParam.fillFrom(pay); err != nil {
+
+		return nil, err
+
+	}
 
 	r = &OrderQueryResult{}
 
@@ -196,7 +200,7 @@ func (pay *Pay) PaymentNotify(r io.Reader, l wx.Logger) (*OrderQueryResult, erro
 
 	result := &OrderQueryResult{}
 
-	if err := pay.decodeResult(&body, result, SIGN_TYPE_MD5); err != nil {
+	if err := pay.decodeResult(&body, result, &payCall{signType: SIGN_TYPE_MD5}); err != nil {
 
 		return nil, err
 
@@ -19,6 +19,12 @@ type Pay struct {
 
 	// Max size when reading incoming result. Default to 8k.
 	MaxResultSize int
+
+	// Source of nonce strings. Default to wx.CryptoNonceSource.
+	NonceSource wx.NonceSource
+
+	// Source of current time, used to check time_expire. Default to wx.SystemClock.
+	Clock wx.Clock
 }
 
 // Create Pay instance from app config and optinal a HTTP client. NOTE:
@@ -59,3 +65,4 @@ func NewPay(config *wx.AppConfig, client *http.Client) (*Pay, error) {
 	}, nil
 
 }
+
@@ -60,7 +60,11 @@ func (pay *Pay) Refund(ctx context.Context, p *RefundParam, l wx.Logger) (
 
 	}
 
-	p.PayParam.fillFrom(pay)
+	if err = p.PayParam.fillFrom(pay); err != nil {
+
+		return nil, err
+
+	}
 
 	if p.OpUserID == "" {
 
@@ -0,0 +1,258 @@
+package pay
+
+import (
+	"context"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"net"
+	"time"
+)
+
+// Common part of transfer (企业付款到零钱) parameters, which uses mch_appid/mchid
+// rather than appid/mch_id.
+type TransferPayParam struct {
+	MchAppID string `wx_pay:"mch_appid"`
+	MchID    string `wx_pay:"mchid"`
+	NonceStr string `wx_pay:"nonce_str"`
+}
+
+func (param *TransferPayParam) fillFrom(pay *Pay) (err error) {
+
+	param.MchAppID = pay.config.AppID
+
+	param.MchID = pay.config.PayMchID
+
+	param.NonceStr, err = wx.NonceStr(pay.NonceSource, 32)
+
+	return
+
+}
+
+// Whether to check the user's real name.
+type CheckName string
+
+const (
+	CHECK_NAME_NO_CHECK    CheckName = "NO_CHECK"
+	CHECK_NAME_FORCE_CHECK CheckName = "FORCE_CHECK"
+)
+
+func (cn *CheckName) marshal() (string, error) {
+
+	return string(*cn), nil
+
+}
+
+func (cn *CheckName) unmarshal(s string) error {
+
+	v := CheckName(s)
+
+	switch v {
+
+	case CHECK_NAME_NO_CHECK, CHECK_NAME_FORCE_CHECK:
+
+		*cn = v
+
+		return nil
+
+	default:
+
+		return fmt.Errorf("Unknown check name %+q", s)
+
+	}
+
+}
+
+// Datetime of format "yyyy-mm-dd HH:MM:SS" used in transfer results.
+type TransferTime time.Time
+
+const transferTimeFmt string = "2006-01-02 15:04:05"
+
+func (tt *TransferTime) marshal() (string, error) {
+
+	return (*time.Time)(tt).Format(transferTimeFmt), nil
+
+}
+
+func (tt *TransferTime) unmarshal(s string) error {
+
+	t, err := time.Parse(transferTimeFmt, s)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	*tt = TransferTime(t)
+
+	return nil
+
+}
+
+type TransferParam struct {
+	TransferPayParam
+
+	// --- Required
+	PartnerTradeNO string    `wx_pay:"partner_trade_no"`
+	OpenID         string    `wx_pay:"openid"`
+	CheckName      CheckName `wx_pay:"check_name"` // Default to CHECK_NAME_NO_CHECK
+	Amount         uint32    `wx_pay:"amount"`
+	Desc           string    `wx_pay:"desc"`
+	SpbillCreateIP string    `wx_pay:"spbill_create_ip"` // IP of the server calling the API
+
+	// --- Optional
+	ReUserName string `wx_pay:"re_user_name"` // Required for CHECK_NAME_FORCE_CHECK
+	DeviceInfo string `wx_pay:"device_info"`
+}
+
+type TransferResult struct {
+	ReturnCode string `wx_pay:"return_code"`
+	ReturnMsg  string `wx_pay:"return_msg"`
+	ResultCode string `wx_pay:"result_code"`
+	ErrCode    string `wx_pay:"err_code"`
+	ErrCodeDes string `wx_pay:"err_code_des"`
+	MchAppID   string `wx_pay:"mch_appid"`
+	MchID      string `wx_pay:"mchid"`
+	DeviceInfo string `wx_pay:"device_info"`
+	NonceStr   string `wx_pay:"nonce_str"`
+
+	PartnerTradeNO string       `wx_pay:"partner_trade_no"`
+	PaymentNO      string       `wx_pay:"payment_no"`
+	PaymentTime    TransferTime `wx_pay:"payment_time"`
+}
+
+// Pay to a user's Wechat balance (企业付款到零钱), requires client certificate.
+// Failed results are returned as *ResultError. NOTE: when it IsSystemError(), the
+// transfer may have succeeded, retry with the same partner_trade_no or check with
+// GetTransferInfo.
+func (pay *Pay) Transfer(ctx context.Context, p *TransferParam, l wx.Logger) (
+	r *TransferResult, err error) {
+
+	if p.PartnerTradeNO == "" || p.OpenID == "" || p.Amount == 0 || p.Desc == "" || p.SpbillCreateIP == "" {
+
+		return nil, fmt.Errorf("Transfer: partner_trade_no/openid/amount/desc/spbill_create_ip are required")
+
+	}
+
+	if net.ParseIP(p.SpbillCreateIP) == nil {
+
+		return nil, fmt.Errorf("Transfer: invalid spbill_create_ip %+q", p.SpbillCreateIP)
+
+	}
+
+	if p.CheckName == "" {
+
+		p.CheckName = CHECK_NAME_NO_CHECK
+
+	}
+
+	if p.CheckName == CHECK_NAME_FORCE_CHECK && p.ReUserName == "" {
+
+		return nil, fmt.Errorf("Transfer: re_user_name is required for FORCE_CHECK")
+
+	}
+
+	if err = p.TransferPayParam.fillFrom(pay); err != nil {
+
+		return nil, err
+
+	}
+
+	r = &TransferResult{}
+
+	// Transfer results are not signed.
+	err = pay.callMD5PayAPI(ctx, "https://api.mch.weixin.qq.com/mmpaymkttransfers/promotion/transfers",
+		p, r, true, l)
+
+	return
+
+}
+
+type TransferStatus string
+
+const (
+	TRANSFER_STATUS_SUCCESS    TransferStatus = "SUCCESS"
+	TRANSFER_STATUS_FAILED     TransferStatus = "FAILED"
+	TRANSFER_STATUS_PROCESSING TransferStatus = "PROCESSING"
+)
+
+func (ts *TransferStatus) marshal() (string, error) {
+
+	return string(*ts), nil
+
+}
+
+func (ts *TransferStatus) unmarshal(s string) error {
+
+	v := TransferStatus(s)
+
+	switch v {
+
+	case TRANSFER_STATUS_SUCCESS, TRANSFER_STATUS_FAILED, TRANSFER_STATUS_PROCESSING:
+
+		*ts = v
+
+		return nil
+
+	default:
+
+		return fmt.Errorf("Unknown transfer status %+q", s)
+
+	}
+
+}
+
+// NOTE: unlike Transfer, this API uses appid/mch_id.
+type GetTransferInfoParam struct {
+	PayParam
+
+	// --- Required
+	PartnerTradeNO string `wx_pay:"partner_trade_no"`
+}
+
+type GetTransferInfoResult struct {
+	ReturnCode string `wx_pay:"return_code"`
+	ReturnMsg  string `wx_pay:"return_msg"`
+	ResultCode string `wx_pay:"result_code"`
+	ErrCode    string `wx_pay:"err_code"`
+	ErrCodeDes string `wx_pay:"err_code_des"`
+	AppID      string `wx_pay:"appid"`
+	MchID      string `wx_pay:"mch_id"`
+
+	PartnerTradeNO string         `wx_pay:"partner_trade_no"`
+	DetailID       string         `wx_pay:"detail_id"` // Same as payment_no
+	Status         TransferStatus `wx_pay:"status"`
+	Reason         string         `wx_pay:"reason"` // Failure reason
+	OpenID         string         `wx_pay:"openid"`
+	TransferName   string         `wx_pay:"transfer_name"`
+	PaymentAmount  uint32         `wx_pay:"payment_amount"`
+	TransferTime   TransferTime   `wx_pay:"transfer_time"`
+	PaymentTime    TransferTime   `wx_pay:"payment_time"`
+	Desc           string         `wx_pay:"desc"`
+}
+
+// Query a transfer by partner_trade_no, requires client certificate.
+func (pay *Pay) GetTransferInfo(ctx context.Context, p *GetTransferInfoParam, l wx.Logger) (
+	r *GetTransferInfoResult, err error) {
+
+	if p.PartnerTradeNO == "" {
+
+		return nil, fmt.Errorf("GetTransferInfo: partner_trade_no missing")
+
+	}
+
+	if err = p.PayParam.fillFrom(pay); err != nil {
+
+		return nil, err
+
+	}
+
+	r = &GetTransferInfoResult{}
+
+	// Transfer results are not signed.
+	err = pay.callMD5PayAPI(ctx, "https://api.mch.weixin.qq.com/mmpaymkttransfers/gettransferinfo",
+		p, r, true, l)
+
+	return
+
+}
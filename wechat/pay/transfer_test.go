@@ -0,0 +1,370 @@
+package pay
+
+import (
+	"context"
+	"errors"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/internal/wxtest"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPayKey = "192006250b4c09247ec02edce69f6a2d"
+
+// Create a Pay talking to a fake server. respond returns the result dict for the
+// request dict (whose sign has been verified), an empty "sign" in the result is
+// replaced by a valid sign.
+func newTestPay(t *testing.T, respond func(req map[string]string) map[string]string) (*Pay, *[]map[string]string) {
+
+	config := &wx.AppConfig{AppID: "wxapp", PayMchID: "10000100", PayKey: testPayKey}
+
+	pay := &Pay{config: config, DefaultSignType: SIGN_TYPE_MD5}
+
+	var reqs []map[string]string
+
+	pay.client = wxtest.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		px := &payXML{}
+
+		if err := px.Decode(r.Body); err != nil {
+
+			t.Error(err)
+			return
+
+		}
+
+		req := px.ToDict()
+
+		reqs = append(reqs, req)
+
+		sign_type := SignType(req["sign_type"])
+
+		if sign_type == "" {
+
+			sign_type = SIGN_TYPE_MD5
+
+		}
+
+		if req["sign"] != pay.Sign(req, sign_type) {
+
+			t.Errorf("bad request sign %+q", req["sign"])
+
+		}
+
+		resp := respond(req)
+
+		if sign, ok := resp["sign"]; ok && sign == "" {
+
+			resp["sign"] = strings.ToUpper(pay.Sign(resp, sign_type))
+
+		}
+
+		px = &payXML{}
+
+		px.FromDict(resp)
+
+		buf, _ := px.Encode()
+
+		w.Header().Set("Content-Type", "text/xml")
+
+		w.Write(buf.Bytes())
+
+	}))
+
+	return pay, &reqs
+
+}
+
+func transferParam() *TransferParam {
+
+	return &TransferParam{
+		PartnerTradeNO: "T0001",
+		OpenID:         "openid1",
+		Amount:         100,
+		Desc:           "refund",
+		SpbillCreateIP: "192.168.0.1",
+	}
+
+}
+
+func transferResult(req map[string]string) map[string]string {
+
+	return map[string]string{
+		"return_code":      "SUCCESS",
+		"result_code":      "SUCCESS",
+		"mch_appid":        req["mch_appid"],
+		"mchid":            req["mchid"],
+		"nonce_str":        "n",
+		"partner_trade_no": req["partner_trade_no"],
+		"payment_no":       "1000018301201505190181489473",
+		"payment_time":     "2015-05-19 15:26:59",
+	}
+
+}
+
+func TestTransfer(t *testing.T) {
+
+	pay, reqs := newTestPay(t, transferResult)
+
+	r, err := pay.Transfer(context.Background(), transferParam(), nil)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if r.PaymentNO != "1000018301201505190181489473" ||
+		!time.Time(r.PaymentTime).Equal(time.Date(2015, 5, 19, 15, 26, 59, 0, time.UTC)) {
+
+		t.Fatalf("unexpected result %+v", r)
+
+	}
+
+	req := (*reqs)[0]
+
+	if _, ok := req["sign_type"]; ok {
+
+		t.Error("transfer should not send sign_type")
+
+	}
+
+	for k, v := range map[string]string{
+		"mch_appid":        "wxapp",
+		"mchid":            "10000100",
+		"check_name":       "NO_CHECK",
+		"amount":           "100",
+		"spbill_create_ip": "192.168.0.1",
+	} {
+
+		if req[k] != v {
+
+			t.Errorf("expect %s=%+q, got %+q", k, v, req[k])
+
+		}
+
+	}
+
+}
+
+// Transfer results are usually unsigned, but a signed one is accepted as well.
+func TestTransferSignedResult(t *testing.T) {
+
+	pay, _ := newTestPay(t, func(req map[string]string) map[string]string {
+
+		ret := transferResult(req)
+
+		ret["sign"] = ""
+
+		return ret
+
+	})
+
+	if _, err := pay.Transfer(context.Background(), transferParam(), nil); err != nil {
+
+		t.Fatal(err)
+
+	}
+
+}
+
+func TestTransferParamCheck(t *testing.T) {
+
+	pay, reqs := newTestPay(t, transferResult)
+
+	for _, f := range []func(p *TransferParam){
+		func(p *TransferParam) { p.SpbillCreateIP = "" },
+		func(p *TransferParam) { p.SpbillCreateIP = "localhost" },
+		func(p *TransferParam) { p.Amount = 0 },
+		func(p *TransferParam) { p.CheckName = CHECK_NAME_FORCE_CHECK },
+	} {
+
+		p := transferParam()
+
+		f(p)
+
+		if _, err := pay.Transfer(context.Background(), p, nil); err == nil {
+
+			t.Errorf("expect error for %+v", p)
+
+		}
+
+	}
+
+	if len(*reqs) != 0 {
+
+		t.Fatal("bad parameters should not be sent")
+
+	}
+
+}
+
+func TestTransferResultError(t *testing.T) {
+
+	for _, c := range []struct {
+		Name          string
+		Result        map[string]string
+		SignVerified  bool
+		IsSystemError bool
+	}{
+		{
+			"system_error",
+			map[string]string{"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "SYSTEMERROR"},
+			true,
+			true,
+		},
+		{
+			"not_enough",
+			map[string]string{"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "NOTENOUGH"},
+			true,
+			false,
+		},
+		{
+			"return_fail",
+			map[string]string{"return_code": "FAIL", "return_msg": "bad cert"},
+			true,
+			false,
+		},
+		{
+			"bad_sign",
+			map[string]string{"return_code": "SUCCESS", "result_code": "SUCCESS", "sign": "BAD"},
+			false,
+			false,
+		},
+	} {
+
+		pay, _ := newTestPay(t, func(req map[string]string) map[string]string {
+
+			ret := make(map[string]string)
+
+			for k, v := range c.Result {
+
+				ret[k] = v
+
+			}
+
+			return ret
+
+		})
+
+		_, err := pay.Transfer(context.Background(), transferParam(), nil)
+
+		var result_err *ResultError
+
+		if !errors.As(err, &result_err) {
+
+			t.Errorf("%s: expect *ResultError, got %v", c.Name, err)
+
+			continue
+
+		}
+
+		if result_err.ErrCode != c.Result["err_code"] || result_err.SignVerified != c.SignVerified ||
+			result_err.IsSystemError() != c.IsSystemError {
+
+			t.Errorf("%s: unexpected error %+v", c.Name, result_err)
+
+		}
+
+	}
+
+}
+
+func TestGetTransferInfo(t *testing.T) {
+
+	pay, reqs := newTestPay(t, func(req map[string]string) map[string]string {
+
+		return map[string]string{
+			"return_code":      "SUCCESS",
+			"result_code":      "SUCCESS",
+			"appid":            req["appid"],
+			"mch_id":           req["mch_id"],
+			"partner_trade_no": req["partner_trade_no"],
+			"detail_id":        "1000000000201503283103439304",
+			"status":           "SUCCESS",
+			"openid":           "openid1",
+			"payment_amount":   "100",
+			"transfer_time":    "2015-04-21 20:00:00",
+			"payment_time":     "2015-04-21 20:00:01",
+			"desc":             "refund",
+		}
+
+	})
+
+	r, err := pay.GetTransferInfo(context.Background(), &GetTransferInfoParam{PartnerTradeNO: "T0001"}, nil)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if r.Status != TRANSFER_STATUS_SUCCESS || r.PaymentAmount != 100 || r.DetailID != "1000000000201503283103439304" {
+
+		t.Fatalf("unexpected result %+v", r)
+
+	}
+
+	if req := (*reqs)[0]; req["appid"] != "wxapp" || req["mch_id"] != "10000100" {
+
+		t.Fatalf("unexpected request %v", req)
+
+	}
+
+}
+
+// Results of normal pay APIs must be signed.
+func TestSignedResultRequired(t *testing.T) {
+
+	for _, c := range []struct {
+		Name     string
+		SignType SignType
+		Sign     bool
+		OK       bool
+	}{
+		{"md5_signed", SIGN_TYPE_MD5, true, true},
+		{"hmac_signed", SIGN_TYPE_HMAC_SHA256, true, true},
+		{"unsigned", SIGN_TYPE_MD5, false, false},
+	} {
+
+		pay, reqs := newTestPay(t, func(req map[string]string) map[string]string {
+
+			ret := map[string]string{
+				"return_code": "SUCCESS",
+				"result_code": "SUCCESS",
+				"appid":       req["appid"],
+				"mch_id":      req["mch_id"],
+				"nonce_str":   "n",
+			}
+
+			if c.Sign {
+
+				ret["sign"] = ""
+
+			}
+
+			return ret
+
+		})
+
+		pay.DefaultSignType = c.SignType
+
+		_, err := pay.CloseOrder(context.Background(), &CloseOrderParam{OutTradeNO: "O0001"}, nil)
+
+		if (err == nil) != c.OK {
+
+			t.Errorf("%s: expect ok=%v, got %v", c.Name, c.OK, err)
+
+		}
+
+		if (*reqs)[0]["sign_type"] != string(c.SignType) {
+
+			t.Errorf("%s: unexpected sign_type %+q", c.Name, (*reqs)[0]["sign_type"])
+
+		}
+
+	}
+
+}
@@ -14,13 +14,15 @@ type PayParam struct {
 	NonceStr string `wx_pay:"nonce_str"`
 }
 
-func (param *PayParam) fillFrom(pay *Pay) {
+func (param *PayParam) fillFrom(pay *Pay) (err error) {
 
 	param.AppID = pay.config.AppID
 
 	param.MchID = pay.config.PayMchID
 
-	param.NonceStr = wx.HexCryptoRandString(32)
+	param.NonceStr, err = wx.NonceStr(pay.NonceSource, 32)
+
+	return
 
 }
 
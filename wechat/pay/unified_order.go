@@ -4,8 +4,12 @@ import (
 	"context"
 	"fmt"
 	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"time"
 )
 
+// Min interval between time_expire and time_start (or current time).
+const MinOrderExpire = time.Minute
+
 type UnifiedOrderParam struct {
 	PayParam
 
@@ -55,7 +59,30 @@ func (pay *Pay) UnifiedOrder(ctx context.Context, p *UnifiedOrderParam, l wx.Log
 
 	}
 
-	p.PayParam.fillFrom(pay)
+	if time_expire := time.Time(p.TimeExpire); !time_expire.IsZero() {
+
+		time_start := time.Time(p.TimeStart)
+
+		if time_start.IsZero() {
+
+			time_start = wx.Now(pay.Clock)
+
+		}
+
+		if time_expire.Before(time_start.Add(MinOrderExpire)) {
+
+			return nil, fmt.Errorf("UnifiedOrder: time_expire should be at least %s after time_start (or now)",
+				MinOrderExpire)
+
+		}
+
+	}
+
+	if err = p.PayParam.fillFrom(pay); err != nil {
+
+		return nil, err
+
+	}
 
 	r = &UnifiedOrderResult{}
 
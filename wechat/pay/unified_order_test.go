@@ -0,0 +1,170 @@
+package pay
+
+import (
+	"context"
+	"errors"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"testing"
+	"time"
+)
+
+var cst = time.FixedZone("CST", 8*3600)
+
+func unifiedOrderParam() *UnifiedOrderParam {
+
+	return &UnifiedOrderParam{
+		TradeType:      TRADE_TYPE_JSAPI,
+		Body:           "goods",
+		OutTradeNO:     "O0001",
+		TotalFee:       1,
+		SpbillCreateIP: "192.168.0.1",
+		NotifyURL:      "https://example.com/notify",
+		OpenID:         "openid1",
+	}
+
+}
+
+func unifiedOrderResult(req map[string]string) map[string]string {
+
+	return map[string]string{
+		"return_code": "SUCCESS",
+		"result_code": "SUCCESS",
+		"appid":       req["appid"],
+		"mch_id":      req["mch_id"],
+		"nonce_str":   "n",
+		"sign":        "",
+		"trade_type":  req["trade_type"],
+		"prepay_id":   "wx201410272009395522657a690389285100",
+	}
+
+}
+
+func TestUnifiedOrder(t *testing.T) {
+
+	pay, reqs := newTestPay(t, unifiedOrderResult)
+
+	pay.NonceSource = wx.NonceSourceFunc(func(n int) (string, error) {
+		return "5K8264ILTKCH16CQ2502SI8ZNMTM67VS"[:n], nil
+	})
+
+	now := time.Date(2014, 10, 27, 20, 9, 0, 0, cst)
+
+	pay.Clock = wx.ClockFunc(func() time.Time { return now })
+
+	p := unifiedOrderParam()
+
+	p.TimeExpire = Datetime(now.Add(30 * time.Minute))
+
+	r, err := pay.UnifiedOrder(context.Background(), p, nil)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if r.PrepayID != "wx201410272009395522657a690389285100" || r.TradeType != TRADE_TYPE_JSAPI {
+
+		t.Fatalf("unexpected result %+v", r)
+
+	}
+
+	req := (*reqs)[0]
+
+	for k, v := range map[string]string{
+		"appid":       "wxapp",
+		"mch_id":      "10000100",
+		"nonce_str":   "5K8264ILTKCH16CQ2502SI8ZNMTM67VS",
+		"sign_type":   "MD5",
+		"time_expire": "20141027203900",
+		"openid":      "openid1",
+	} {
+
+		if req[k] != v {
+
+			t.Errorf("expect %s=%+q, got %+q", k, v, req[k])
+
+		}
+
+	}
+
+	if _, ok := req["time_start"]; ok {
+
+		t.Error("zero time_start should not be sent")
+
+	}
+
+}
+
+func TestUnifiedOrderTimeExpire(t *testing.T) {
+
+	pay, reqs := newTestPay(t, unifiedOrderResult)
+
+	now := time.Date(2014, 10, 27, 20, 9, 0, 0, cst)
+
+	pay.Clock = wx.ClockFunc(func() time.Time { return now })
+
+	for _, c := range []struct {
+		Name       string
+		TimeStart  time.Time
+		TimeExpire time.Time
+		OK         bool
+	}{
+		{"no_expire", time.Time{}, time.Time{}, true},
+		{"expire_from_now", time.Time{}, now.Add(MinOrderExpire), true},
+		{"expire_too_soon", time.Time{}, now.Add(MinOrderExpire - time.Second), false},
+		{"expired", time.Time{}, now.Add(-time.Hour), false},
+		{"expire_from_start", now.Add(time.Hour), now.Add(time.Hour + MinOrderExpire), true},
+		{"expire_before_start", now.Add(time.Hour), now.Add(30 * time.Minute), false},
+	} {
+
+		*reqs = nil
+
+		p := unifiedOrderParam()
+
+		p.TimeStart = Datetime(c.TimeStart)
+
+		p.TimeExpire = Datetime(c.TimeExpire)
+
+		_, err := pay.UnifiedOrder(context.Background(), p, nil)
+
+		if (err == nil) != c.OK {
+
+			t.Errorf("%s: expect ok=%v, got %v", c.Name, c.OK, err)
+
+		}
+
+		if !c.OK && len(*reqs) != 0 {
+
+			t.Errorf("%s: bad parameters should not be sent", c.Name)
+
+		}
+
+	}
+
+}
+
+// Errors of NonceSource are returned instead of panicking.
+func TestUnifiedOrderNonceError(t *testing.T) {
+
+	pay, reqs := newTestPay(t, unifiedOrderResult)
+
+	expect := errors.New("entropy exhausted")
+
+	pay.NonceSource = wx.NonceSourceFunc(func(n int) (string, error) {
+		return "", expect
+	})
+
+	if _, err := pay.UnifiedOrder(context.Background(), unifiedOrderParam(), nil); err != expect {
+
+		t.Fatalf("expect nonce error, got %v", err)
+
+	}
+
+	if len(*reqs) != 0 {
+
+		t.Fatal("request should not be sent")
+
+	}
+
+}
@@ -0,0 +1,128 @@
+package wechat
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+)
+
+// Max size of body kept in HTTPError/ContentTypeError.
+const errorBodySnippetSize = 512
+
+// Max size to drain from an unread response body before closing it, so that
+// the underlying connection can be reused.
+const maxDrainSize = 64 * 1024
+
+// Content types accepted when decoding JSON results.
+var JSONContentTypes = []string{"application/json", "text/json", "text/plain"}
+
+// Content types accepted when decoding XML results.
+var XMLContentTypes = []string{"text/xml", "application/xml", "text/plain"}
+
+// HTTPError is returned when an API responds with a non-2xx status.
+type HTTPError struct {
+	StatusCode  int
+	Status      string
+	ContentType string
+	// The beginning of the response body.
+	Body []byte
+}
+
+func (e *HTTPError) Error() string {
+
+	return fmt.Sprintf("http status=%+q content_type=%+q body=%+q", e.Status, e.ContentType, e.Body)
+
+}
+
+// ContentTypeError is returned when an API responds with an unexpected content type,
+// e.g. a HTML page from a proxy.
+type ContentTypeError struct {
+	ContentType string
+	// The beginning of the response body.
+	Body []byte
+}
+
+func (e *ContentTypeError) Error() string {
+
+	return fmt.Sprintf("unexpected content_type=%+q body=%+q", e.ContentType, e.Body)
+
+}
+
+// Close resp.Body after draining (part of) what is left.
+func CloseResponse(resp *http.Response) {
+
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDrainSize))
+
+	resp.Body.Close()
+
+}
+
+// Check resp's status and content type, then read its body into w (see LimitRead).
+// resp.Body is always drained and closed. A non-2xx status results in *HTTPError.
+// If accept_types is not empty and the response has a Content-Type header, its media
+// type must be one of accept_types, otherwise *ContentTypeError is returned.
+func ReadResponse(resp *http.Response, w io.Writer, max int64, accept_types ...string) error {
+
+	defer CloseResponse(resp)
+
+	content_type := resp.Header.Get("Content-Type")
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+
+		snippet, _ := ioutil.ReadAll(io.LimitReader(resp.Body, errorBodySnippetSize))
+
+		return &HTTPError{
+			StatusCode:  resp.StatusCode,
+			Status:      resp.Status,
+			ContentType: content_type,
+			Body:        snippet,
+		}
+
+	}
+
+	if !acceptContentType(content_type, accept_types) {
+
+		snippet, _ := ioutil.ReadAll(io.LimitReader(resp.Body, errorBodySnippetSize))
+
+		return &ContentTypeError{
+			ContentType: content_type,
+			Body:        snippet,
+		}
+
+	}
+
+	return LimitRead(resp.Body, w, max)
+
+}
+
+func acceptContentType(content_type string, accept_types []string) bool {
+
+	if len(accept_types) == 0 || content_type == "" {
+
+		return true
+
+	}
+
+	media_type, _, err := mime.ParseMediaType(content_type)
+
+	if err != nil {
+
+		return false
+
+	}
+
+	for _, t := range accept_types {
+
+		if media_type == t {
+
+			return true
+
+		}
+
+	}
+
+	return false
+
+}
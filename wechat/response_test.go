@@ -0,0 +1,130 @@
+package wechat
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type testBody struct {
+	*strings.Reader
+	closed bool
+}
+
+func (b *testBody) Close() error {
+
+	b.closed = true
+
+	return nil
+
+}
+
+func TestReadResponse(t *testing.T) {
+
+	html := "<html><head><title>502 Bad Gateway</title></head><body>" + strings.Repeat(".", 1024) + "</body></html>"
+
+	for _, c := range []struct {
+		Name        string
+		StatusCode  int
+		ContentType string
+		Body        string
+		Max         int64
+		Expect      string
+		Err         interface{}
+	}{
+		{"ok", 200, "application/json; charset=utf-8", `{"errcode":0}`, 64, `{"errcode":0}`, nil},
+		{"text_plain", 200, "text/plain", `{"errcode":0}`, 64, `{"errcode":0}`, nil},
+		{"no_content_type", 200, "", `{"errcode":0}`, 64, `{"errcode":0}`, nil},
+		{"max", 200, "application/json", `{"errcode":0}`, 13, `{"errcode":0}`, nil},
+		{"exceed_max", 200, "application/json", `{"errcode":0}`, 12, "", "max (12)"},
+		{"html_page", 200, "text/html; charset=utf-8", html, 4096, "", &ContentTypeError{}},
+		{"xml_for_json", 200, "text/xml", `<xml></xml>`, 64, "", &ContentTypeError{}},
+		{"bad_content_type", 200, "application/", `{"errcode":0}`, 64, "", &ContentTypeError{}},
+		{"html_error_page", 502, "text/html", html, 4096, "", &HTTPError{}},
+		{"redirect", 302, "", "", 64, "", &HTTPError{}},
+	} {
+
+		body := &testBody{Reader: strings.NewReader(c.Body)}
+
+		resp := &http.Response{
+			StatusCode: c.StatusCode,
+			Status:     http.StatusText(c.StatusCode),
+			Header:     http.Header{},
+			Body:       body,
+		}
+
+		if c.ContentType != "" {
+
+			resp.Header.Set("Content-Type", c.ContentType)
+
+		}
+
+		var buf bytes.Buffer
+
+		err := ReadResponse(resp, &buf, c.Max, JSONContentTypes...)
+
+		if !body.closed {
+
+			t.Errorf("%s: body not closed", c.Name)
+
+		}
+
+		switch expect := c.Err.(type) {
+
+		case nil:
+
+			if err != nil || buf.String() != c.Expect {
+
+				t.Errorf("%s: expect %+q, got %+q %v", c.Name, c.Expect, buf.String(), err)
+
+			}
+
+		case string:
+
+			if err == nil || !strings.Contains(err.Error(), expect) {
+
+				t.Errorf("%s: expect error containing %+q, got %v", c.Name, expect, err)
+
+			}
+
+		case *ContentTypeError:
+
+			var ct_err *ContentTypeError
+
+			if !errors.As(err, &ct_err) || ct_err.ContentType != c.ContentType ||
+				len(ct_err.Body) > errorBodySnippetSize || !strings.HasPrefix(c.Body, string(ct_err.Body)) {
+
+				t.Errorf("%s: expect ContentTypeError, got %v", c.Name, err)
+
+			}
+
+			if buf.Len() != 0 {
+
+				t.Errorf("%s: body should not be written", c.Name)
+
+			}
+
+		case *HTTPError:
+
+			var http_err *HTTPError
+
+			if !errors.As(err, &http_err) || http_err.StatusCode != c.StatusCode ||
+				len(http_err.Body) > errorBodySnippetSize || !strings.HasPrefix(c.Body, string(http_err.Body)) {
+
+				t.Errorf("%s: expect HTTPError, got %v", c.Name, err)
+
+			}
+
+			if buf.Len() != 0 {
+
+				t.Errorf("%s: body should not be written", c.Name)
+
+			}
+
+		}
+
+	}
+
+}
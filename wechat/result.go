@@ -0,0 +1,74 @@
+package wechat
+
+import (
+	"fmt"
+)
+
+// Common part of JSON API results.
+type ResultBase struct {
+	// ErrCode default to 0, which is good since no errcode means success
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// Is ErrCode == 0 ?
+func (r *ResultBase) OK() bool {
+
+	return r.ErrCode == 0
+
+}
+
+// Return *APIError if not OK().
+func (r *ResultBase) Error() error {
+
+	if r.OK() {
+
+		return nil
+
+	}
+
+	return &APIError{
+		ErrCode: r.ErrCode,
+		ErrMsg:  r.ErrMsg,
+	}
+
+}
+
+// Common errcodes of JSON APIs.
+const (
+	ERRCODE_SYSTEM_BUSY           = -1
+	ERRCODE_INVALID_CREDENTIAL    = 40001 // access_token invalid or not latest
+	ERRCODE_INVALID_ACCESS_TOKEN  = 40014
+	ERRCODE_ACCESS_TOKEN_EXPIRED  = 42001
+	ERRCODE_API_FREQ_OUT_OF_LIMIT = 45009
+	ERRCODE_API_UNAUTHORIZED      = 48001
+)
+
+// APIError is a failed JSON API result (errcode != 0). Use errors.As to extract it.
+type APIError struct {
+	ErrCode int
+	ErrMsg  string
+}
+
+func (e *APIError) Error() string {
+
+	return fmt.Sprintf("errcode=%v errmsg=%+q", e.ErrCode, e.ErrMsg)
+
+}
+
+// The access token used is invalid or expired.
+func (e *APIError) IsAccessTokenError() bool {
+
+	switch e.ErrCode {
+
+	case ERRCODE_INVALID_CREDENTIAL, ERRCODE_INVALID_ACCESS_TOKEN, ERRCODE_ACCESS_TOKEN_EXPIRED:
+
+		return true
+
+	default:
+
+		return false
+
+	}
+
+}
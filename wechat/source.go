@@ -0,0 +1,62 @@
+package wechat
+
+import (
+	"time"
+)
+
+// Source of nonce strings used in signed payloads.
+type NonceSource interface {
+	// Return a nonce string of length n.
+	NonceStr(n int) (string, error)
+}
+
+// Source of current time used in signed payloads and expiry checking.
+type Clock interface {
+	Now() time.Time
+}
+
+// Adapter to use an ordinary function as NonceSource.
+type NonceSourceFunc func(n int) (string, error)
+
+func (f NonceSourceFunc) NonceStr(n int) (string, error) {
+	return f(n)
+}
+
+// Adapter to use an ordinary function as Clock.
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// The default NonceSource: HexCryptoRandString.
+var CryptoNonceSource NonceSource = NonceSourceFunc(HexCryptoRandString)
+
+// The default Clock: time.Now.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// Return a nonce string of length n from s, or from CryptoNonceSource if s is nil.
+func NonceStr(s NonceSource, n int) (string, error) {
+
+	if s == nil {
+
+		return CryptoNonceSource.NonceStr(n)
+
+	}
+
+	return s.NonceStr(n)
+
+}
+
+// Return current time from c, or from SystemClock if c is nil.
+func Now(c Clock) time.Time {
+
+	if c == nil {
+
+		return SystemClock.Now()
+
+	}
+
+	return c.Now()
+
+}
@@ -0,0 +1,277 @@
+package subscribe
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Type of a keyword, which is also the prefix of its key, e.g. "thing" of "thing1".
+type ValueType string
+
+const (
+	VALUE_TYPE_THING            ValueType = "thing"
+	VALUE_TYPE_NUMBER           ValueType = "number"
+	VALUE_TYPE_LETTER           ValueType = "letter"
+	VALUE_TYPE_SYMBOL           ValueType = "symbol"
+	VALUE_TYPE_CHARACTER_STRING ValueType = "character_string"
+	VALUE_TYPE_TIME             ValueType = "time"
+	VALUE_TYPE_DATE             ValueType = "date"
+	VALUE_TYPE_AMOUNT           ValueType = "amount"
+	VALUE_TYPE_PHONE_NUMBER     ValueType = "phone_number"
+	VALUE_TYPE_CAR_NUMBER       ValueType = "car_number"
+	VALUE_TYPE_NAME             ValueType = "name"
+	VALUE_TYPE_PHRASE           ValueType = "phrase"
+)
+
+var (
+	numberRegexp          = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+	letterRegexp          = regexp.MustCompile(`^[A-Za-z]+$`)
+	symbolRegexp          = regexp.MustCompile(`^[^\p{L}\p{N}]+$`)
+	characterStringRegexp = regexp.MustCompile(`^[\x21-\x7e]+$`)
+	amountRegexp          = regexp.MustCompile(`^\p{Sc}?[0-9]{1,10}(\.[0-9]+)?元?$`)
+	phoneNumberRegexp     = regexp.MustCompile(`^[0-9+\-() ]+$`)
+	carNumberRegexp       = regexp.MustCompile(`^\p{Han}?[A-Za-z0-9]+\p{Han}?$`)
+	nameRegexp            = regexp.MustCompile(`^[\p{L} .·]+$`)
+	asciiLetterRegexp     = regexp.MustCompile(`^[A-Za-z .]+$`)
+	phraseRegexp          = regexp.MustCompile(`^\p{Han}+$`)
+)
+
+var (
+	timeLayouts = []string{
+		"15:04",
+		"15:04:05",
+		"2006年1月2日 15:04",
+		"2006-01-02 15:04",
+		"2006-01-02 15:04:05",
+	}
+	dateLayouts = []string{
+		"2006年1月2日",
+		"2006-01-02",
+		"2006年1月2日 15:04",
+		"2006-01-02 15:04",
+		"2006-01-02 15:04:05",
+	}
+)
+
+// Value of a keyword.
+type Value struct {
+	Value string `json:"value"`
+}
+
+// Keyword key (e.g. "thing1", "amount2") -> value.
+type Data map[string]Value
+
+// Set a keyword's value.
+func (d Data) Set(key, value string) Data {
+
+	d[key] = Value{Value: value}
+
+	return d
+
+}
+
+// Return the type of a keyword key, i.e. the key without trailing digits.
+func KeyType(key string) ValueType {
+
+	return ValueType(strings.TrimRight(key, "0123456789"))
+
+}
+
+// Validate all values against their types (derived from keys). Keys of unknown
+// types are not checked.
+func (d Data) Validate() error {
+
+	if len(d) == 0 {
+
+		return fmt.Errorf("Data: empty")
+
+	}
+
+	for key, v := range d {
+
+		if err := KeyType(key).Validate(v.Value); err != nil {
+
+			return fmt.Errorf("Data: %s: %s", key, err)
+
+		}
+
+	}
+
+	return nil
+
+}
+
+// Is it a type listed in Wechat's docs ?
+func (t ValueType) Known() bool {
+
+	switch t {
+
+	case VALUE_TYPE_THING, VALUE_TYPE_NUMBER, VALUE_TYPE_LETTER, VALUE_TYPE_SYMBOL,
+		VALUE_TYPE_CHARACTER_STRING, VALUE_TYPE_TIME, VALUE_TYPE_DATE, VALUE_TYPE_AMOUNT,
+		VALUE_TYPE_PHONE_NUMBER, VALUE_TYPE_CAR_NUMBER, VALUE_TYPE_NAME, VALUE_TYPE_PHRASE:
+
+		return true
+
+	default:
+
+		return false
+
+	}
+
+}
+
+// Validate a value against the documented constraints of the type. Values of
+// unknown types are accepted.
+func (t ValueType) Validate(value string) error {
+
+	if value == "" {
+
+		return fmt.Errorf("empty value")
+
+	}
+
+	n := utf8.RuneCountInString(value)
+
+	check := func(ok bool, desc string) error {
+
+		if ok {
+
+			return nil
+
+		}
+
+		return fmt.Errorf("%q is not a valid %s (%s)", value, t, desc)
+
+	}
+
+	switch t {
+
+	case VALUE_TYPE_THING:
+		return check(n <= 20, "at most 20 chars")
+
+	case VALUE_TYPE_NUMBER:
+		return check(n <= 32 && numberRegexp.MatchString(value), "at most 32 digits, may have decimals")
+
+	case VALUE_TYPE_LETTER:
+		return check(n <= 32 && letterRegexp.MatchString(value), "at most 32 letters")
+
+	case VALUE_TYPE_SYMBOL:
+		return check(n <= 5 && symbolRegexp.MatchString(value), "at most 5 symbols")
+
+	case VALUE_TYPE_CHARACTER_STRING:
+		return check(n <= 32 && characterStringRegexp.MatchString(value),
+			"at most 32 digits, letters or symbols")
+
+	case VALUE_TYPE_TIME:
+		return check(parsableRange(value, timeLayouts), "24-hour time, may have date, or a range of them joined by ~")
+
+	case VALUE_TYPE_DATE:
+		return check(parsableRange(value, dateLayouts), "date, may have 24-hour time, or a range of them joined by ~")
+
+	case VALUE_TYPE_AMOUNT:
+		return check(amountRegexp.MatchString(value),
+			"optional currency symbol, at most 10 digits, may have decimals and end with 元")
+
+	case VALUE_TYPE_PHONE_NUMBER:
+		return check(n <= 17 && phoneNumberRegexp.MatchString(value), "at most 17 digits or symbols")
+
+	case VALUE_TYPE_CAR_NUMBER:
+		return check(n <= 8 && carNumberRegexp.MatchString(value),
+			"at most 8 letters or digits, the first and last may be chinese chars")
+
+	case VALUE_TYPE_NAME:
+		if !nameRegexp.MatchString(value) {
+			return check(false, "letters or chinese chars, no digits or symbols")
+		}
+		if asciiLetterRegexp.MatchString(value) {
+			return check(n <= 20, "at most 20 letters")
+		}
+		return check(n <= 10, "at most 10 chars")
+
+	case VALUE_TYPE_PHRASE:
+		return check(n <= 5 && phraseRegexp.MatchString(value), "at most 5 chinese chars")
+
+	default:
+		return nil
+
+	}
+
+}
+
+// A single value or a range "a ~ b", e.g. "2019年10月1日 15:01 ~ 2019年10月2日 15:01".
+func parsableRange(value string, layouts []string) bool {
+
+	parts := strings.Split(value, "~")
+
+	if len(parts) > 2 {
+
+		return false
+
+	}
+
+	for _, part := range parts {
+
+		if !parsable(strings.TrimSpace(part), layouts) {
+
+			return false
+
+		}
+
+	}
+
+	return true
+
+}
+
+func parsable(value string, layouts []string) bool {
+
+	for _, layout := range layouts {
+
+		if _, err := time.Parse(layout, value); err == nil {
+
+			return true
+
+		}
+
+	}
+
+	return false
+
+}
+
+// Validate data against keywords of the template: all keywords should be present
+// and no extra key is allowed.
+func (d Data) ValidateKeywords(keywords []Keyword) error {
+
+	keys := make(map[string]bool)
+
+	for i := range keywords {
+
+		key := keywords[i].Key()
+
+		if _, ok := d[key]; !ok {
+
+			return fmt.Errorf("Data: keyword %s (%s) missing", key, keywords[i].Name)
+
+		}
+
+		keys[key] = true
+
+	}
+
+	for key := range d {
+
+		if !keys[key] {
+
+			return fmt.Errorf("Data: unknown keyword %s", key)
+
+		}
+
+	}
+
+	return d.Validate()
+
+}
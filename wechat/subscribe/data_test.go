@@ -0,0 +1,102 @@
+package subscribe
+
+import (
+	"testing"
+)
+
+func TestValidateTimeDate(t *testing.T) {
+
+	for _, c := range []struct {
+		key   string
+		value string
+		ok    bool
+	}{
+		// --- Single values
+		{"time1", "15:01", true},
+		{"time1", "15:01:02", true},
+		{"time1", "2019年10月1日 15:01", true},
+		{"time1", "2019-10-01 15:01", true},
+		{"date2", "2019年10月1日", true},
+		{"date2", "2019-10-01", true},
+		{"date2", "2019年10月1日 15:01", true},
+		{"time1", "25:01", false},
+		{"time1", "3pm", false},
+		{"date2", "2019/10/01", false},
+		{"date2", "", false},
+
+		// --- Ranges
+		{"time1", "15:01~15:30", true},
+		{"time1", "15:01 ~ 15:30", true},
+		{"time1", "2019年10月1日 15:01 ~ 2019年10月2日 15:01", true},
+		{"date2", "2019年10月1日 ~ 2019年10月2日", true},
+		{"date2", "2019-10-01~2019-10-02", true},
+		{"date2", "2019年10月1日 15:01 ~ 2019年10月2日 15:01", true},
+
+		// --- Malformed ranges
+		{"time1", "15:01 ~", false},
+		{"time1", "~ 15:30", false},
+		{"time1", "~", false},
+		{"time1", "15:01 ~ 15:30 ~ 16:00", false},
+		{"time1", "15:01 ~ later", false},
+		{"date2", "2019年10月1日 ~ 2019/10/02", false},
+		{"date2", "2019年10月1日 ~~ 2019年10月2日", false},
+	} {
+
+		err := KeyType(c.key).Validate(c.value)
+
+		if ok := err == nil; ok != c.ok {
+
+			t.Errorf("Validate(%s, %q): expect ok=%v but got %v", c.key, c.value, c.ok, err)
+
+		}
+
+	}
+
+}
+
+func TestValidateCarNumberName(t *testing.T) {
+
+	for _, c := range []struct {
+		key   string
+		value string
+		ok    bool
+	}{
+		// --- Car numbers
+		{"car_number1", "粤A12345", true},
+		{"car_number1", "粤B1234学", true},
+		{"car_number1", "京AD12345", true},
+		{"car_number1", "WJ12345", true},
+		{"car_number1", "粤A1234567", false},
+		{"car_number1", "粤A-12345", false},
+		{"car_number1", "粤A 1234", false},
+		{"car_number1", "粤粤12345", false},
+		{"car_number1", "粤", false},
+		{"car_number1", "", false},
+
+		// --- Names
+		{"name2", "张三", true},
+		{"name2", "欧阳娜娜娜娜娜娜娜娜", true},
+		{"name2", "欧阳娜娜娜娜娜娜娜娜娜", false},
+		{"name2", "John Smith", true},
+		{"name2", "J. R. R. Tolkien", true},
+		{"name2", "Abcdefghijklmnopqrst", true},
+		{"name2", "Abcdefghijklmnopqrstu", false},
+		{"name2", "阿卜杜·热合曼", true},
+		{"name2", "张三Lee", true},
+		{"name2", "张三1", false},
+		{"name2", "Agent 007", false},
+		{"name2", "张三!", false},
+		{"name2", "<script>", false},
+	} {
+
+		err := KeyType(c.key).Validate(c.value)
+
+		if ok := err == nil; ok != c.ok {
+
+			t.Errorf("Validate(%s, %q): expect ok=%v but got %v", c.key, c.value, c.ok, err)
+
+		}
+
+	}
+
+}
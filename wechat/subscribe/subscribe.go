@@ -0,0 +1,173 @@
+// Package subscribe supports subscribe messages (订阅消息) of both mini programs
+// (subscribeMessage.send) and official accounts (subscribe/bizsend). The popup and
+// change events of official accounts are in package mp/server.
+package subscribe
+
+import (
+	"context"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/token"
+	"net/url"
+	"strconv"
+)
+
+// Errcodes of subscribe message APIs.
+const (
+	ERRCODE_INVALID_TEMPLATE_ID = 40037
+	ERRCODE_INVALID_DATA        = 47003 // Data does not meet the keyword's rule
+	ERRCODE_USER_REFUSED        = 43101 // User has not subscribed (or quota used up)
+)
+
+// Mini program version to jump to.
+type MiniProgramState string
+
+const (
+	MINIPROGRAM_STATE_DEVELOPER MiniProgramState = "developer"
+	MINIPROGRAM_STATE_TRIAL     MiniProgramState = "trial"
+	MINIPROGRAM_STATE_FORMAL    MiniProgramState = "formal"
+)
+
+// Language of the mini program to jump to.
+type Lang string
+
+const (
+	LANG_ZH_CN Lang = "zh_CN"
+	LANG_EN_US Lang = "en_US"
+	LANG_ZH_HK Lang = "zh_HK"
+	LANG_ZH_TW Lang = "zh_TW"
+)
+
+// Send subscribe messages using the app access token manager (of a mini program
+// or an official account).
+type Subscribe struct {
+	tokens *token.AccessTokenManager
+}
+
+// Create Subscribe instance using the app access token manager.
+func NewSubscribe(tokens *token.AccessTokenManager) (*Subscribe, error) {
+
+	if tokens == nil {
+
+		return nil, fmt.Errorf("NewSubscribe: tokens missing")
+
+	}
+
+	return &Subscribe{
+		tokens: tokens,
+	}, nil
+
+}
+
+// Message for mini program's subscribeMessage.send.
+type MiniProgramMessage struct {
+	// --- Required
+	ToUser     string `json:"touser"`
+	TemplateID string `json:"template_id"`
+	Data       Data   `json:"data"`
+
+	// --- Optional
+	Page             string           `json:"page,omitempty"`
+	MiniProgramState MiniProgramState `json:"miniprogram_state,omitempty"`
+	Lang             Lang             `json:"lang,omitempty"`
+}
+
+// Send a subscribe message of mini program. Data is validated before sending.
+func (s *Subscribe) SendMiniProgram(ctx context.Context, msg *MiniProgramMessage, l wx.Logger) error {
+
+	if msg.ToUser == "" || msg.TemplateID == "" {
+
+		return fmt.Errorf("SendMiniProgram: touser/template_id are required")
+
+	}
+
+	if err := msg.Data.Validate(); err != nil {
+
+		return err
+
+	}
+
+	return s.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/message/subscribe/send",
+		msg, nil, l)
+
+}
+
+// Jump to a mini program when the message is clicked.
+type MiniProgram struct {
+	AppID    string `json:"appid"`
+	PagePath string `json:"pagepath,omitempty"`
+}
+
+// Message for official account's subscribe/bizsend.
+type BizMessage struct {
+	// --- Required
+	ToUser     string `json:"touser"`
+	TemplateID string `json:"template_id"`
+	Data       Data   `json:"data"`
+
+	// --- Optional, mini program jump takes precedence over Page
+	Page        string       `json:"page,omitempty"` // An URL
+	MiniProgram *MiniProgram `json:"miniprogram,omitempty"`
+}
+
+// Send a subscribe message of official account. Data is validated before sending.
+func (s *Subscribe) SendBiz(ctx context.Context, msg *BizMessage, l wx.Logger) error {
+
+	if msg.ToUser == "" || msg.TemplateID == "" {
+
+		return fmt.Errorf("SendBiz: touser/template_id are required")
+
+	}
+
+	if msg.MiniProgram != nil && msg.MiniProgram.AppID == "" {
+
+		return fmt.Errorf("SendBiz: miniprogram's appid is required")
+
+	}
+
+	if err := msg.Data.Validate(); err != nil {
+
+		return err
+
+	}
+
+	return s.tokens.CallAPI(ctx, "https://api.weixin.qq.com/cgi-bin/message/subscribe/bizsend",
+		msg, nil, l)
+
+}
+
+// A keyword of a public template.
+type Keyword struct {
+	KID     int       `json:"kid"`
+	Name    string    `json:"name"`
+	Example string    `json:"example"`
+	Rule    ValueType `json:"rule"`
+}
+
+// Return the key used in Data, e.g. "thing1".
+func (k *Keyword) Key() string {
+	return string(k.Rule) + strconv.Itoa(k.KID)
+}
+
+type getPubTemplateKeywordsResult struct {
+	wx.ResultBase
+
+	Count int       `json:"count"`
+	Data  []Keyword `json:"data"`
+}
+
+// Get keywords of a public template (by its tid).
+func (s *Subscribe) GetPubTemplateKeywords(ctx context.Context, tid string, l wx.Logger) ([]Keyword, error) {
+
+	r := &getPubTemplateKeywordsResult{}
+
+	if err := s.tokens.CallAPI(ctx, "https://api.weixin.qq.com/wxaapi/newtmpl/getpubtemplatekeywords?"+
+		url.Values{"tid": []string{tid}}.Encode(), nil, r, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return r.Data, nil
+
+}
@@ -0,0 +1,206 @@
+package token
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"net/http"
+	"net/url"
+)
+
+// Call a JSON API requiring access token. URL should not contain access_token. If
+// in is not nil, it is encoded as JSON and POSTed, otherwise a GET is sent. The
+// result is decoded into out if not nil. Return *wx.APIError when errcode != 0.
+//
+// When the access token is reported invalid or expired (40001/40014/42001), it is
+// force refreshed and the call is retried once.
+func (m *AccessTokenManager) CallAPI(ctx context.Context, URL string, in, out interface{}, l wx.Logger) error {
+
+	var body []byte
+
+	if in != nil {
+
+		buf := new(bytes.Buffer)
+
+		enc := json.NewEncoder(buf)
+
+		// Wechat does not unescape < and alike.
+		enc.SetEscapeHTML(false)
+
+		if err := enc.Encode(in); err != nil {
+
+			return err
+
+		}
+
+		body = buf.Bytes()
+
+	}
+
+	access_token, err := m.Token(ctx, l)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	err = m.callAPI(ctx, URL, access_token, body, out, l)
+
+	var api_err *wx.APIError
+
+	if !errors.As(err, &api_err) || !api_err.IsAccessTokenError() {
+
+		return err
+
+	}
+
+	if access_token, err = m.ForceRefresh(ctx, access_token, l); err != nil {
+
+		return err
+
+	}
+
+	return m.callAPI(ctx, URL, access_token, body, out, l)
+
+}
+
+// Append access_token to URL.
+func WithAccessToken(URL, access_token string) (string, error) {
+
+	u, err := url.Parse(URL)
+
+	if err != nil {
+
+		return "", err
+
+	}
+
+	q := u.Query()
+
+	q.Set("access_token", access_token)
+
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+
+}
+
+func (m *AccessTokenManager) newRequest(ctx context.Context, URL, access_token string, body []byte,
+	l wx.Logger) (*http.Request, error) {
+
+	if l != nil {
+
+		if body != nil {
+
+			l.Printf("method=\"POST\" url=%+q body=%+q\n", URL, body)
+
+		} else {
+
+			l.Printf("method=\"GET\" url=%+q\n", URL)
+
+		}
+
+	}
+
+	full_url, err := WithAccessToken(URL, access_token)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	if body == nil {
+
+		req, err := http.NewRequest("GET", full_url, nil)
+
+		if err != nil {
+
+			return nil, err
+
+		}
+
+		return req.WithContext(ctx), nil
+
+	}
+
+	req, err := http.NewRequest("POST", full_url, bytes.NewReader(body))
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	return req.WithContext(ctx), nil
+
+}
+
+func (m *AccessTokenManager) callAPI(ctx context.Context, URL, access_token string, body []byte,
+	out interface{}, l wx.Logger) (err error) {
+
+	var (
+		req  *http.Request
+		resp *http.Response
+		buf  bytes.Buffer
+	)
+
+	if req, err = m.newRequest(ctx, URL, access_token, body, l); err != nil {
+
+		return
+
+	}
+
+	if resp, err = m.client.Do(req); err != nil {
+
+		return
+
+	}
+
+	if err = wx.ReadResponse(resp, &buf, int64(m.maxResultSize()), wx.JSONContentTypes...); err != nil {
+
+		return
+
+	}
+
+	if l != nil {
+
+		l.Printf("status=%+q proto=%+q body=%+q\n", resp.Status, resp.Proto, buf.Bytes())
+
+	}
+
+	return DecodeResult(buf.Bytes(), out)
+
+}
+
+// Decode a JSON API result into out (if not nil). Return *wx.APIError when errcode != 0.
+func DecodeResult(data []byte, out interface{}) error {
+
+	base := &wx.ResultBase{}
+
+	if err := json.Unmarshal(data, base); err != nil {
+
+		return err
+
+	}
+
+	if err := base.Error(); err != nil {
+
+		return err
+
+	}
+
+	if out == nil {
+
+		return nil
+
+	}
+
+	return json.Unmarshal(data, out)
+
+}
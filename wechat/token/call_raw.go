@@ -0,0 +1,177 @@
+package token
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+)
+
+// Call an API which responds with raw content (e.g. an image or a file) on success
+// and a JSON error otherwise. If in is not nil, it is encoded as JSON and POSTed,
+// otherwise a GET is sent. On success the body is streamed into w without size
+// limit, and the response header is returned (for Content-Type and alike).
+//
+// A JSON response (detected by content type) is decoded into out and nothing is
+// written into w. It results in *wx.APIError when errcode != 0 (the access token
+// is refreshed and the call is retried once as CallAPI does), or an error if out
+// is nil (JSON not expected).
+func (m *AccessTokenManager) CallRawAPI(ctx context.Context, URL string, in interface{}, w io.Writer,
+	out interface{}, l wx.Logger) (http.Header, error) {
+
+	var body []byte
+
+	if in != nil {
+
+		buf := new(bytes.Buffer)
+
+		enc := json.NewEncoder(buf)
+
+		enc.SetEscapeHTML(false)
+
+		if err := enc.Encode(in); err != nil {
+
+			return nil, err
+
+		}
+
+		body = buf.Bytes()
+
+	}
+
+	access_token, err := m.Token(ctx, l)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	header, err := m.callRawAPI(ctx, URL, access_token, body, w, out, l)
+
+	var api_err *wx.APIError
+
+	if !errors.As(err, &api_err) || !api_err.IsAccessTokenError() {
+
+		return header, err
+
+	}
+
+	if access_token, err = m.ForceRefresh(ctx, access_token, l); err != nil {
+
+		return nil, err
+
+	}
+
+	return m.callRawAPI(ctx, URL, access_token, body, w, out, l)
+
+}
+
+func (m *AccessTokenManager) callRawAPI(ctx context.Context, URL, access_token string, body []byte,
+	w io.Writer, out interface{}, l wx.Logger) (http.Header, error) {
+
+	req, err := m.newRequest(ctx, URL, access_token, body, l)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	resp, err := m.client.Do(req)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	defer wx.CloseResponse(resp)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+
+		// Returns *wx.HTTPError.
+		return nil, wx.ReadResponse(resp, ioutil.Discard, 0)
+
+	}
+
+	if isJSONContentType(resp.Header.Get("Content-Type")) {
+
+		var buf bytes.Buffer
+
+		if err := wx.LimitRead(resp.Body, &buf, int64(m.maxResultSize())); err != nil {
+
+			return nil, err
+
+		}
+
+		if l != nil {
+
+			l.Printf("status=%+q proto=%+q body=%+q\n", resp.Status, resp.Proto, buf.Bytes())
+
+		}
+
+		if err := DecodeResult(buf.Bytes(), out); err != nil {
+
+			return nil, err
+
+		}
+
+		if out == nil {
+
+			return nil, fmt.Errorf("CallRawAPI: unexpected JSON result %+q", buf.Bytes())
+
+		}
+
+		return resp.Header, nil
+
+	}
+
+	n, err := io.Copy(w, resp.Body)
+
+	if l != nil {
+
+		l.Printf("status=%+q proto=%+q content_type=%+q body_size=%d\n", resp.Status, resp.Proto,
+			resp.Header.Get("Content-Type"), n)
+
+	}
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	return resp.Header, nil
+
+}
+
+func isJSONContentType(content_type string) bool {
+
+	media_type, _, err := mime.ParseMediaType(content_type)
+
+	if err != nil {
+
+		return false
+
+	}
+
+	for _, t := range wx.JSONContentTypes {
+
+		if media_type == t {
+
+			return true
+
+		}
+
+	}
+
+	return false
+
+}
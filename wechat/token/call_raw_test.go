@@ -0,0 +1,126 @@
+package token
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"net/http"
+	"testing"
+)
+
+var jpegData = []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00fake jpeg")
+
+func TestCallRawAPI(t *testing.T) {
+
+	var tokens []string
+
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+
+		access_token := r.URL.Query().Get("access_token")
+
+		tokens = append(tokens, access_token)
+
+		switch r.URL.Path {
+
+		case "/image":
+
+			// Rejected token T1.
+			if access_token == "T1" {
+
+				w.Header().Set("Content-Type", "application/json; encoding=utf-8")
+
+				fmt.Fprint(w, `{"errcode":40001,"errmsg":"invalid credential"}`)
+
+				return
+
+			}
+
+			w.Header().Set("Content-Type", "image/jpeg")
+
+			w.Write(jpegData)
+
+		case "/plain_error":
+
+			w.Header().Set("Content-Type", "text/plain")
+
+			fmt.Fprint(w, `{"errcode":45009,"errmsg":"reach max api daily quota limit"}`)
+
+		case "/not_found":
+
+			http.NotFound(w, r)
+
+		}
+
+	})
+
+	// 40001 -> ForceRefresh -> retry.
+	var buf bytes.Buffer
+
+	header, err := m.CallRawAPI(context.Background(), "https://api.weixin.qq.com/image",
+		map[string]string{"path": "a"}, &buf, &wx.ResultBase{}, nil)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if header.Get("Content-Type") != "image/jpeg" || !bytes.Equal(buf.Bytes(), jpegData) {
+
+		t.Fatalf("unexpected result %v %+q", header, buf.Bytes())
+
+	}
+
+	if len(tokens) != 2 || tokens[0] != "T1" || tokens[1] != "T2" {
+
+		t.Fatalf("expect calls with T1 then T2, got %v", tokens)
+
+	}
+
+	// Streamed body with the new token.
+	buf.Reset()
+
+	if _, err := m.CallRawAPI(context.Background(), "https://api.weixin.qq.com/image", nil, &buf, nil,
+		nil); err != nil || !bytes.Equal(buf.Bytes(), jpegData) {
+
+		t.Fatalf("unexpected result %v %+q", err, buf.Bytes())
+
+	}
+
+	// JSON error with text/plain content type.
+	for _, out := range []interface{}{&wx.ResultBase{}, nil} {
+
+		buf.Reset()
+
+		_, err = m.CallRawAPI(context.Background(), "https://api.weixin.qq.com/plain_error", nil, &buf, out, nil)
+
+		var api_err *wx.APIError
+
+		if !errors.As(err, &api_err) || api_err.ErrCode != 45009 {
+
+			t.Errorf("expect APIError 45009, got %v", err)
+
+		}
+
+		if buf.Len() != 0 {
+
+			t.Errorf("error should not be written, got %+q", buf.Bytes())
+
+		}
+
+	}
+
+	// Non-2xx.
+	_, err = m.CallRawAPI(context.Background(), "https://api.weixin.qq.com/not_found", nil, &buf, nil, nil)
+
+	var http_err *wx.HTTPError
+
+	if !errors.As(err, &http_err) || http_err.StatusCode != http.StatusNotFound {
+
+		t.Fatalf("expect HTTPError 404, got %v", err)
+
+	}
+
+}
@@ -0,0 +1,232 @@
+package token
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+)
+
+// Returned to the form writer when the request ends before the form is fully sent.
+var errUploadAborted = errors.New("upload aborted")
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// A file to upload in multipart form.
+type UploadFile struct {
+	// Form field name, default to "media".
+	FieldName string
+	// Wechat checks the file type by its extension.
+	FileName string
+	// Content type of the file, default to the one by FileName's extension.
+	ContentType string
+	// The content, it's streamed without being loaded into memory.
+	Reader io.Reader
+}
+
+// Call a JSON API which takes a multipart form (fields and a file). The result is
+// decoded into out if not nil. Return *wx.APIError when errcode != 0.
+//
+// The access token is refreshed and the call is retried once as CallAPI does, only
+// when file.Reader is an io.Seeker (so that it can be rewound).
+func (m *AccessTokenManager) CallUploadAPI(ctx context.Context, URL string, fields map[string]string,
+	file *UploadFile, out interface{}, l wx.Logger) error {
+
+	var (
+		seeker io.Seeker
+		offset int64
+	)
+
+	if s, ok := file.Reader.(io.Seeker); ok {
+
+		var err error
+
+		if offset, err = s.Seek(0, io.SeekCurrent); err == nil {
+
+			seeker = s
+
+		}
+
+	}
+
+	access_token, err := m.Token(ctx, l)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	err = m.callUploadAPI(ctx, URL, access_token, fields, file, out, l)
+
+	var api_err *wx.APIError
+
+	if seeker == nil || !errors.As(err, &api_err) || !api_err.IsAccessTokenError() {
+
+		return err
+
+	}
+
+	if access_token, err = m.ForceRefresh(ctx, access_token, l); err != nil {
+
+		return err
+
+	}
+
+	if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+
+		return err
+
+	}
+
+	return m.callUploadAPI(ctx, URL, access_token, fields, file, out, l)
+
+}
+
+func (m *AccessTokenManager) callUploadAPI(ctx context.Context, URL, access_token string,
+	fields map[string]string, file *UploadFile, out interface{}, l wx.Logger) error {
+
+	if l != nil {
+
+		l.Printf("method=\"POST\" url=%+q fields=%+q file=%+q\n", URL, fields, file.FileName)
+
+	}
+
+	full_url, err := WithAccessToken(URL, access_token)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	pr, pw := io.Pipe()
+
+	mw := multipart.NewWriter(pw)
+
+	done := make(chan struct{})
+
+	// Stream the form.
+	go func() {
+
+		defer close(done)
+
+		pw.CloseWithError(writeMultipart(mw, fields, file))
+
+	}()
+
+	// The transport may return (error or early response) before reading the whole
+	// form. Abort the writer and wait for it, so that file.Reader is no longer used
+	// (e.g. rewound for retry) after return.
+	defer func() {
+
+		pr.CloseWithError(errUploadAborted)
+
+		<-done
+
+	}()
+
+	req, err := http.NewRequest("POST", full_url, pr)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	resp, err := m.client.Do(req.WithContext(ctx))
+
+	if err != nil {
+
+		return err
+
+	}
+
+	var buf bytes.Buffer
+
+	if err := wx.ReadResponse(resp, &buf, int64(m.maxResultSize()), wx.JSONContentTypes...); err != nil {
+
+		return err
+
+	}
+
+	if l != nil {
+
+		l.Printf("status=%+q proto=%+q body=%+q\n", resp.Status, resp.Proto, buf.Bytes())
+
+	}
+
+	return DecodeResult(buf.Bytes(), out)
+
+}
+
+func writeMultipart(mw *multipart.Writer, fields map[string]string, file *UploadFile) error {
+
+	for k, v := range fields {
+
+		if err := mw.WriteField(k, v); err != nil {
+
+			return err
+
+		}
+
+	}
+
+	field_name := file.FieldName
+
+	if field_name == "" {
+
+		field_name = "media"
+
+	}
+
+	content_type := file.ContentType
+
+	if content_type == "" {
+
+		content_type = mime.TypeByExtension(filepath.Ext(file.FileName))
+
+	}
+
+	if content_type == "" {
+
+		content_type = "application/octet-stream"
+
+	}
+
+	h := make(textproto.MIMEHeader)
+
+	// Not RFC 2231 encoded (as mime.FormatMediaType does for non-ascii names), which
+	// Wechat does not understand.
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(field_name), quoteEscaper.Replace(file.FileName)))
+
+	h.Set("Content-Type", content_type)
+
+	w, err := mw.CreatePart(h)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	if _, err := io.Copy(w, file.Reader); err != nil {
+
+		return err
+
+	}
+
+	return mw.Close()
+
+}
@@ -0,0 +1,147 @@
+package token
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/internal/wxtest"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+// Create a manager talking to a test server, cgi-bin/token issues "T1", "T2"...
+func newTestManager(t *testing.T, h http.HandlerFunc) *AccessTokenManager {
+
+	var n int32
+
+	client := wxtest.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Path == "/cgi-bin/token" {
+
+			fmt.Fprintf(w, `{"access_token":"T%d","expires_in":7200}`, atomic.AddInt32(&n, 1))
+
+			return
+
+		}
+
+		h(w, r)
+
+	}))
+
+	m, err := NewAccessTokenManager(&wx.AppConfig{AppID: "app", AppSecret: "secret"}, client)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	return m
+
+}
+
+// The first upload is rejected (stale token) before the form is fully read, the
+// retry must rewind the reader only after the first writer stops. Run with -race.
+func TestCallUploadAPIRetry(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 256*1024) // 4M
+
+	var calls int32
+
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Query().Get("access_token") == "T1" {
+
+			atomic.AddInt32(&calls, 1)
+
+			// Respond without reading the body.
+			fmt.Fprint(w, `{"errcode":40001,"errmsg":"invalid credential"}`)
+
+			return
+
+		}
+
+		atomic.AddInt32(&calls, 1)
+
+		f, _, err := r.FormFile("media")
+
+		if err != nil {
+
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+
+		}
+
+		data, _ := ioutil.ReadAll(f)
+
+		if !bytes.Equal(data, content) {
+
+			fmt.Fprintf(w, `{"errcode":1,"errmsg":"content mismatch (%d bytes)"}`, len(data))
+
+			return
+
+		}
+
+		fmt.Fprintf(w, `{"media_id":"M1","type":%+q}`, r.FormValue("type"))
+
+	})
+
+	out := &struct {
+		MediaID string `json:"media_id"`
+		Type    string `json:"type"`
+	}{}
+
+	err := m.CallUploadAPI(context.Background(), "https://api.weixin.qq.com/cgi-bin/media/upload",
+		map[string]string{"type": "image"}, &UploadFile{
+			FileName: "a.jpg",
+			Reader:   bytes.NewReader(content),
+		}, out, nil)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	if out.MediaID != "M1" || out.Type != "image" {
+
+		t.Fatalf("unexpected result %+v", out)
+
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 2 {
+
+		t.Fatalf("expect 2 upload calls but got %d", n)
+
+	}
+
+}
+
+// Readers which can't be rewound are not retried.
+func TestCallUploadAPINoRetry(t *testing.T) {
+
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {
+
+		fmt.Fprint(w, `{"errcode":40001,"errmsg":"invalid credential"}`)
+
+	})
+
+	err := m.CallUploadAPI(context.Background(), "https://api.weixin.qq.com/cgi-bin/media/upload",
+		nil, &UploadFile{
+			FileName: "a.jpg",
+			Reader:   ioutil.NopCloser(bytes.NewReader(make([]byte, 1024*1024))),
+		}, nil, nil)
+
+	api_err, ok := err.(*wx.APIError)
+
+	if !ok || api_err.ErrCode != wx.ERRCODE_INVALID_CREDENTIAL {
+
+		t.Fatalf("expect 40001 but got %v", err)
+
+	}
+
+}
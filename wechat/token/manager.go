@@ -0,0 +1,404 @@
+// Package token manages app level access tokens (client_credential), which are
+// required by most Wechat APIs other than OAuth2, and provides a helper to call
+// these APIs.
+package token
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Default value of AccessTokenManager.RefreshAhead.
+const DefaultRefreshAhead = 5 * time.Minute
+
+// AccessTokenManager caches an app's access token and refreshes it ahead of
+// expiry. Concurrent refreshes are deduplicated.
+type AccessTokenManager struct {
+	// The app configuration.
+	config *wx.AppConfig
+
+	// The client to do http API call.
+	client *http.Client
+
+	// Shared token storage. Default to a MemoryStore.
+	Store Store
+
+	// If true, use cgi-bin/stable_token instead of cgi-bin/token. Stable tokens
+	// obtained in normal mode do not invalidate each other.
+	UseStableToken bool
+
+	// Refresh token when it expires within this duration. Default to DefaultRefreshAhead.
+	RefreshAhead time.Duration
+
+	// Source of current time. Default to wx.SystemClock.
+	Clock wx.Clock
+
+	// Max size when reading incoming result. Default to 1M.
+	MaxResultSize int
+
+	// Timeout of a (shared) refresh. Default to wx.DefaultAPITimeout.
+	RefreshTimeout time.Duration
+
+	mu       sync.Mutex
+	cached   *Token
+	inflight *call
+}
+
+// An in-flight refresh.
+type call struct {
+	done  chan struct{}
+	stale string
+	token *Token
+	err   error
+}
+
+// Create AccessTokenManager instance from app config (and optional a HTTP client).
+// The config should contain valid AppID and AppSecret.
+func NewAccessTokenManager(config *wx.AppConfig, client *http.Client) (*AccessTokenManager, error) {
+
+	if config == nil || config.AppID == "" || config.AppSecret == "" {
+
+		return nil, fmt.Errorf("NewAccessTokenManager: config's AppID/AppSecret missing")
+
+	}
+
+	if client == nil {
+
+		client = &http.Client{
+			Timeout: wx.DefaultAPITimeout,
+		}
+
+	}
+
+	return &AccessTokenManager{
+		config: config,
+		client: client,
+		Store:  NewMemoryStore(),
+	}, nil
+
+}
+
+// Return the config associated.
+func (m *AccessTokenManager) Config() *wx.AppConfig {
+	return m.config
+}
+
+// Return the HTTP client associated.
+func (m *AccessTokenManager) Client() *http.Client {
+	return m.client
+}
+
+func (m *AccessTokenManager) maxResultSize() int {
+
+	max_result_size := m.MaxResultSize
+
+	if max_result_size <= 0 {
+
+		max_result_size = 1024 * 1024
+
+	}
+
+	return max_result_size
+
+}
+
+// Is the token usable and not about to expire ?
+func (m *AccessTokenManager) fresh(token *Token) bool {
+
+	if token == nil || token.AccessToken == "" {
+
+		return false
+
+	}
+
+	refresh_ahead := m.RefreshAhead
+
+	if refresh_ahead <= 0 {
+
+		refresh_ahead = DefaultRefreshAhead
+
+	}
+
+	return wx.Now(m.Clock).Add(refresh_ahead).Before(token.ExpiresAt)
+
+}
+
+// Return a valid access token.
+func (m *AccessTokenManager) Token(ctx context.Context, l wx.Logger) (string, error) {
+
+	m.mu.Lock()
+	cached := m.cached
+	m.mu.Unlock()
+
+	if m.fresh(cached) {
+
+		return cached.AccessToken, nil
+
+	}
+
+	token, err := m.refresh(ctx, "", l)
+
+	if err != nil {
+
+		return "", err
+
+	}
+
+	return token.AccessToken, nil
+
+}
+
+// Force refreshing the access token, e.g. when an API reports it invalid. stale is
+// the token considered invalid: if the current token is already different from it
+// (refreshed by others), the current one is returned without refreshing.
+func (m *AccessTokenManager) ForceRefresh(ctx context.Context, stale string, l wx.Logger) (string, error) {
+
+	token, err := m.refresh(ctx, stale, l)
+
+	if err != nil {
+
+		return "", err
+
+	}
+
+	return token.AccessToken, nil
+
+}
+
+// Refresh (deduplicated). If stale is empty, a fresh token from store is used
+// directly; otherwise any fresh token other than stale is used, and a token is
+// fetched with force if none.
+//
+// The shared refresh runs on its own context (bounded by RefreshTimeout) so that
+// a canceled caller does not fail the others; each caller only gives up waiting
+// when its own ctx is done.
+func (m *AccessTokenManager) refresh(ctx context.Context, stale string, l wx.Logger) (*Token, error) {
+
+	for {
+
+		m.mu.Lock()
+
+		c := m.inflight
+
+		if c == nil {
+
+			c = &call{done: make(chan struct{}), stale: stale}
+
+			m.inflight = c
+
+			go m.doCall(c, l)
+
+		}
+
+		m.mu.Unlock()
+
+		select {
+
+		case <-c.done:
+
+		case <-ctx.Done():
+
+			return nil, ctx.Err()
+
+		}
+
+		// A forced refresh joined a call not forced on stale (e.g. a normal refresh
+		// using the stored token), which may return stale: run again.
+		if stale != "" && c.stale != stale && c.err == nil && c.token.AccessToken == stale {
+
+			continue
+
+		}
+
+		return c.token, c.err
+
+	}
+
+}
+
+func (m *AccessTokenManager) doCall(c *call, l wx.Logger) {
+
+	refresh_timeout := m.RefreshTimeout
+
+	if refresh_timeout <= 0 {
+
+		refresh_timeout = wx.DefaultAPITimeout
+
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), refresh_timeout)
+
+	defer cancel()
+
+	c.token, c.err = m.doRefresh(ctx, c.stale, l)
+
+	m.mu.Lock()
+
+	if c.err == nil {
+
+		m.cached = c.token
+
+	}
+
+	m.inflight = nil
+
+	m.mu.Unlock()
+
+	close(c.done)
+
+}
+
+func (m *AccessTokenManager) doRefresh(ctx context.Context, stale string, l wx.Logger) (*Token, error) {
+
+	appid := m.config.AppID
+
+	// Others (maybe another process) may have refreshed it.
+	stored, err := m.Store.Load(ctx, appid)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	if m.fresh(stored) && (stale == "" || stored.AccessToken != stale) {
+
+		return stored, nil
+
+	}
+
+	token, err := m.fetch(ctx, stale != "", l)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	if err := m.Store.Save(ctx, appid, token); err != nil {
+
+		return nil, err
+
+	}
+
+	return token, nil
+
+}
+
+type tokenResult struct {
+	wx.ResultBase
+
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Fetch a new token from Wechat.
+func (m *AccessTokenManager) fetch(ctx context.Context, force bool, l wx.Logger) (*Token, error) {
+
+	var (
+		req *http.Request
+		err error
+	)
+
+	if m.UseStableToken {
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"grant_type":    "client_credential",
+			"appid":         m.config.AppID,
+			"secret":        m.config.AppSecret,
+			"force_refresh": force,
+		})
+
+		URL := "https://api.weixin.qq.com/cgi-bin/stable_token"
+
+		if l != nil {
+
+			l.Printf("method=\"POST\" url=%+q force_refresh=%v\n", URL, force)
+
+		}
+
+		if req, err = http.NewRequest("POST", URL, bytes.NewReader(body)); err != nil {
+
+			return nil, err
+
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+
+	} else {
+
+		if l != nil {
+
+			l.Printf("method=\"GET\" url=%+q\n", "https://api.weixin.qq.com/cgi-bin/token")
+
+		}
+
+		if req, err = http.NewRequest("GET", "https://api.weixin.qq.com/cgi-bin/token?"+url.Values{
+			"grant_type": []string{"client_credential"},
+			"appid":      []string{m.config.AppID},
+			"secret":     []string{m.config.AppSecret},
+		}.Encode(), nil); err != nil {
+
+			return nil, err
+
+		}
+
+	}
+
+	now := wx.Now(m.Clock)
+
+	resp, err := m.client.Do(req.WithContext(ctx))
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	var buf bytes.Buffer
+
+	if err := wx.ReadResponse(resp, &buf, int64(m.maxResultSize()), wx.JSONContentTypes...); err != nil {
+
+		return nil, err
+
+	}
+
+	if l != nil {
+
+		l.Printf("status=%+q proto=%+q body_size=%d\n", resp.Status, resp.Proto, buf.Len())
+
+	}
+
+	r := &tokenResult{}
+
+	if err := json.NewDecoder(&buf).Decode(r); err != nil {
+
+		return nil, err
+
+	}
+
+	if err := r.Error(); err != nil {
+
+		return nil, err
+
+	}
+
+	if r.AccessToken == "" {
+
+		return nil, fmt.Errorf("Empty access_token")
+
+	}
+
+	return &Token{
+		AccessToken: r.AccessToken,
+		ExpiresAt:   now.Add(time.Duration(r.ExpiresIn) * time.Second),
+	}, nil
+
+}
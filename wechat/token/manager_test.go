@@ -0,0 +1,188 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	wx "github.com/huangjunwen/WechatDriver/wechat"
+	"github.com/huangjunwen/WechatDriver/wechat/internal/wxtest"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The caller starting a shared refresh is canceled, the other waiters must still
+// get the token.
+func TestRefreshDetachedFromCaller(t *testing.T) {
+
+	started := make(chan struct{})
+
+	release := make(chan struct{})
+
+	client := wxtest.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		close(started)
+
+		<-release
+
+		fmt.Fprint(w, `{"access_token":"T1","expires_in":7200}`)
+
+	}))
+
+	m, err := NewAccessTokenManager(&wx.AppConfig{AppID: "app", AppSecret: "secret"}, client)
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+
+	err1 := make(chan error, 1)
+
+	go func() {
+
+		_, err := m.Token(ctx1, nil)
+
+		err1 <- err
+
+	}()
+
+	<-started
+
+	token2 := make(chan string, 1)
+
+	go func() {
+
+		token, err := m.Token(context.Background(), nil)
+
+		if err != nil {
+
+			t.Error(err)
+
+		}
+
+		token2 <- token
+
+	}()
+
+	cancel1()
+
+	if err := <-err1; err != context.Canceled {
+
+		t.Fatalf("expect context.Canceled for the first caller, got %v", err)
+
+	}
+
+	close(release)
+
+	select {
+
+	case token := <-token2:
+
+		if token != "T1" {
+
+			t.Fatalf("expect T1, got %+q", token)
+
+		}
+
+	case <-time.After(5 * time.Second):
+
+		t.Fatal("second caller blocked")
+
+	}
+
+}
+
+// A Store whose first Load blocks until released.
+type gatedStore struct {
+	Store
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (s *gatedStore) Load(ctx context.Context, appid string) (*Token, error) {
+
+	s.once.Do(func() {
+
+		close(s.entered)
+
+		<-s.release
+
+	})
+
+	return s.Store.Load(ctx, appid)
+
+}
+
+// ForceRefresh(stale) joining a normal refresh (which returns the stored token)
+// must not get stale back.
+func TestForceRefreshJoiningNormalRefresh(t *testing.T) {
+
+	m := newTestManager(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	store := &gatedStore{
+		Store:   NewMemoryStore(),
+		entered: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+
+	// Saved by another process, but rejected by Wechat later.
+	store.Store.Save(context.Background(), "app", &Token{AccessToken: "S", ExpiresAt: time.Now().Add(time.Hour)})
+
+	m.Store = store
+
+	token1 := make(chan string, 1)
+
+	go func() {
+
+		token, err := m.Token(context.Background(), nil)
+
+		if err != nil {
+
+			t.Error(err)
+
+		}
+
+		token1 <- token
+
+	}()
+
+	<-store.entered
+
+	token2 := make(chan string, 1)
+
+	go func() {
+
+		token, err := m.ForceRefresh(context.Background(), "S", nil)
+
+		if err != nil {
+
+			t.Error(err)
+
+		}
+
+		token2 <- token
+
+	}()
+
+	// Let ForceRefresh join the in-flight call.
+	time.Sleep(50 * time.Millisecond)
+
+	close(store.release)
+
+	if token := <-token1; token != "S" {
+
+		t.Fatalf("expect S for Token, got %+q", token)
+
+	}
+
+	if token := <-token2; token != "T1" {
+
+		t.Fatalf("expect T1 for ForceRefresh, got %+q", token)
+
+	}
+
+}
@@ -0,0 +1,68 @@
+package token
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// An app access token.
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Storage of app access tokens keyed by appid. Processes sharing the same app
+// should share the same store (e.g. backed by redis), so that they don't
+// invalidate each other's token by refreshing separately. Implementations must be
+// safe for concurrent use.
+type Store interface {
+	// Load a token. Return nil token (and nil error) if not found.
+	Load(ctx context.Context, appid string) (*Token, error)
+
+	// Save (or replace) a token.
+	Save(ctx context.Context, appid string, token *Token) error
+}
+
+// In-memory Store.
+type MemoryStore struct {
+	mu     sync.Mutex
+	tokens map[string]Token
+}
+
+// Create an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+
+	return &MemoryStore{
+		tokens: make(map[string]Token),
+	}
+
+}
+
+func (s *MemoryStore) Load(ctx context.Context, appid string) (*Token, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[appid]
+
+	if !ok {
+
+		return nil, nil
+
+	}
+
+	return &token, nil
+
+}
+
+func (s *MemoryStore) Save(ctx context.Context, appid string, token *Token) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[appid] = *token
+
+	return nil
+
+}
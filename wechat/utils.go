@@ -33,7 +33,7 @@ func LimitRead(r io.Reader, w io.Writer, max int64) error {
 }
 
 // Generate a cryptographically secure hex string of length n.
-func HexCryptoRandString(n int) string {
+func HexCryptoRandString(n int) (string, error) {
 
 	buf := make([]byte, (n>>1)+(n&1))
 
@@ -41,11 +41,11 @@ func HexCryptoRandString(n int) string {
 
 	if err != nil {
 
-		panic(err)
+		return "", err
 
 	}
 
-	return fmt.Sprintf("%x", buf)[:n]
+	return fmt.Sprintf("%x", buf)[:n], nil
 
 }
 